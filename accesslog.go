@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Access log formats
+const (
+	AccessLogOff      = "off"
+	AccessLogCommon   = "common"
+	AccessLogCombined = "combined"
+	AccessLogJSON     = "json"
+)
+
+// clfTimeLayout is the time layout of the common log format
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogEntry is a request as logged in the JSON format
+type accessLogEntry struct {
+	Time      string  `json:"time"`
+	Remote    string  `json:"remote"`
+	User      string  `json:"user,omitempty"`
+	Method    string  `json:"method"`
+	URI       string  `json:"uri"`
+	Proto     string  `json:"proto"`
+	Status    int     `json:"status"`
+	Bytes     int64   `json:"bytes"`
+	Duration  float64 `json:"duration_ms"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"user_agent,omitempty"`
+}
+
+// remoteHost returns the address of the client without the port
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clfField returns a value for the common log format, "-" if it's empty.
+// Quotes, backslashes and control characters are escaped like Apache does,
+// so a request can't forge log lines
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// formatAccessLog returns the log line of a request, without the newline
+func formatAccessLog(format string, r *http.Request, status int, size int64, start time.Time, d time.Duration) string {
+	user, _, _ := r.BasicAuth()
+
+	if format == AccessLogJSON {
+		line, _ := json.Marshal(accessLogEntry{
+			Time:      start.Format(time.RFC3339),
+			Remote:    remoteHost(r),
+			User:      user,
+			Method:    r.Method,
+			URI:       r.RequestURI,
+			Proto:     r.Proto,
+			Status:    status,
+			Bytes:     size,
+			Duration:  float64(d.Microseconds()) / 1000,
+			Referer:   r.Referer(),
+			UserAgent: r.UserAgent(),
+		})
+		return string(line)
+	}
+
+	bytes := "-"
+	if size > 0 {
+		bytes = strconv.FormatInt(size, 10)
+	}
+	// %h %l %u %t "%r" %>s %b
+	line := fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s`,
+		remoteHost(r), clfField(user), start.Format(clfTimeLayout),
+		clfField(r.Method), clfField(r.RequestURI), clfField(r.Proto), status, bytes)
+	if format == AccessLogCombined {
+		// "%{Referer}i" "%{User-agent}i"
+		line += fmt.Sprintf(` "%s" "%s"`, clfField(r.Referer()), clfField(r.UserAgent()))
+	}
+	return line
+}
+
+// AccessLog writes a line per request to w in one of the access log formats
+func AccessLog(w io.Writer, format string, next http.Handler) http.Handler {
+	var mu sync.Mutex
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		line := formatAccessLog(format, r, rec.status, rec.size, start, time.Since(start))
+
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(w, line+"\n")
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFormatAccessLog(t *testing.T) {
+	start := time.Date(2024, 3, 5, 14, 7, 9, 0, time.FixedZone("", 3600))
+
+	tests := []struct {
+		name    string
+		format  string
+		request func() *http.Request
+		status  int
+		size    int64
+		want    string
+	}{
+		{
+			name:   "common",
+			format: AccessLogCommon,
+			request: func() *http.Request {
+				return httptest.NewRequest("GET", "/post/hello?x=1", nil)
+			},
+			status: 200,
+			size:   1234,
+			want:   `192.0.2.1 - - [05/Mar/2024:14:07:09 +0100] "GET /post/hello?x=1 HTTP/1.1" 200 1234`,
+		},
+		{
+			name:   "combined",
+			format: AccessLogCombined,
+			request: func() *http.Request {
+				r := httptest.NewRequest("GET", "/post/hello", nil)
+				r.Header.Set("Referer", "https://example.org/")
+				r.Header.Set("User-Agent", "Mozilla/5.0 (X11)")
+				return r
+			},
+			status: 200,
+			size:   1234,
+			want:   `192.0.2.1 - - [05/Mar/2024:14:07:09 +0100] "GET /post/hello HTTP/1.1" 200 1234 "https://example.org/" "Mozilla/5.0 (X11)"`,
+		},
+		{
+			name:   "combined without referer or user agent",
+			format: AccessLogCombined,
+			request: func() *http.Request {
+				return httptest.NewRequest("HEAD", "/", nil)
+			},
+			status: 304,
+			size:   0,
+			want:   `192.0.2.1 - - [05/Mar/2024:14:07:09 +0100] "HEAD / HTTP/1.1" 304 - "-" "-"`,
+		},
+		{
+			name:   "user",
+			format: AccessLogCommon,
+			request: func() *http.Request {
+				r := httptest.NewRequest("GET", "/admin/", nil)
+				r.SetBasicAuth("frank", "secret")
+				return r
+			},
+			status: 200,
+			size:   5,
+			want:   `192.0.2.1 - frank [05/Mar/2024:14:07:09 +0100] "GET /admin/ HTTP/1.1" 200 5`,
+		},
+		{
+			name:   "escaped fields",
+			format: AccessLogCombined,
+			request: func() *http.Request {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("User-Agent", "evil\" \\ \n200 0")
+				return r
+			},
+			status: 200,
+			size:   10,
+			want:   `192.0.2.1 - - [05/Mar/2024:14:07:09 +0100] "GET / HTTP/1.1" 200 10 "-" "evil\" \\ \x0a200 0"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatAccessLog(tt.format, tt.request(), tt.status, tt.size, start, time.Millisecond)
+			if got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	start := time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC)
+	r := httptest.NewRequest("GET", "/feed.xml", nil)
+	r.Header.Set("User-Agent", "reader")
+
+	line := formatAccessLog(AccessLogJSON, r, 200, 42, start, 1500*time.Microsecond)
+	var entry accessLogEntry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		t.Fatalf("%s isn't JSON: %v", line, err)
+	}
+	want := accessLogEntry{
+		Time:      "2024-03-05T14:07:09Z",
+		Remote:    "192.0.2.1",
+		Method:    "GET",
+		URI:       "/feed.xml",
+		Proto:     "HTTP/1.1",
+		Status:    200,
+		Bytes:     42,
+		Duration:  1.5,
+		UserAgent: "reader",
+	}
+	if entry != want {
+		t.Errorf("got %+v, want %+v", entry, want)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	combined := regexp.MustCompile(`^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /missing HTTP/1\.1" 404 5 "https://example\.org/" "tester"\n$`)
+
+	var out bytes.Buffer
+	handler := AccessLog(&out, AccessLogCombined, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "nope\n")
+	}))
+
+	r := httptest.NewRequest("GET", "/missing", nil)
+	r.Header.Set("Referer", "https://example.org/")
+	r.Header.Set("User-Agent", "tester")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !combined.MatchString(out.String()) {
+		t.Errorf("unexpected log line %q", out.String())
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminConfig holds the credentials of the admin routes, which are off unless
+// a password is set
+type AdminConfig struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// BasicAuth only lets through requests with the admin credentials
+func BasicAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(config.Admin.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(config.Admin.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ReloadPostHandler parses a single post again, ignoring the render cache, and
+// returns its front matter. Handy after editing something the post includes,
+// which doesn't change the post file
+func ReloadPostHandler(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	file, err := findPostFile(slug)
+	if err != nil {
+		log.Printf("Post not found: %s", slug)
+		http.NotFound(w, r)
+		return
+	}
+	renderCache.Remove(file)
+
+	post, err := GetPost(slug)
+	if os.IsNotExist(err) {
+		log.Printf("Post not found: %s", slug)
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error reloading post: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if !postCache.Replace(post) {
+		// Not loaded yet, so the next GetAllPosts picks it up anyway
+		log.Printf("Reloaded post %s, which wasn't in the post cache", file)
+	} else {
+		log.Printf("Reloaded post %s", file)
+	}
+	writePostMeta(w, post)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// decorativeAlt is the alt text of Markdown images that are only decoration,
+// as in ![""](swirl.png)
+const decorativeAlt = `""`
+
+// markDecorativeImages gives the decorative images of a post an empty alt
+// and the presentation role, which tells screen readers and -check that the
+// missing alt text is on purpose
+func markDecorativeImages(doc ast.Node) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		image, ok := node.(*ast.Image)
+		if !ok || !entering || len(image.Children) != 1 {
+			return ast.GoToNext
+		}
+		if text, ok := image.Children[0].(*ast.Text); ok && string(text.Literal) == decorativeAlt {
+			image.Children = nil
+			image.Attribute = &ast.Attribute{Attrs: map[string][]byte{"role": []byte("presentation")}}
+		}
+		return ast.GoToNext
+	})
+}
+
+// imgPattern matches the img tags in rendered HTML
+var imgPattern = regexp.MustCompile(`(?i)<img\b[^>]*>`)
+
+// imgAttrPattern matches the attributes of an img tag
+var imgAttrPattern = regexp.MustCompile(`(?i)\b(alt|src|role)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// imagesWithoutAlt returns the sources of the images in body without alt
+// text, leaving out the ones marked as decorative
+func imagesWithoutAlt(body string) []string {
+	var missing []string
+	for _, tag := range imgPattern.FindAllString(body, -1) {
+		attrs := map[string]string{}
+		for _, m := range imgAttrPattern.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(m[1])] = m[2] + m[3]
+		}
+		if strings.TrimSpace(attrs["alt"]) != "" {
+			continue
+		}
+		if _, hasAlt := attrs["alt"]; hasAlt && (attrs["role"] == "presentation" || attrs["role"] == "none") {
+			continue
+		}
+		missing = append(missing, attrs["src"])
+	}
+	return missing
+}
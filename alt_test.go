@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImagesWithoutAlt(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "alt text", body: `<img src="a.png" alt="A cat">`, want: nil},
+		{name: "no alt", body: `<img src="a.png">`, want: []string{"a.png"}},
+		{name: "empty alt", body: `<img src="a.png" alt="">`, want: []string{"a.png"}},
+		{name: "blank alt", body: `<img src="a.png" alt="  ">`, want: []string{"a.png"}},
+		{name: "decorative", body: `<img src="a.png" alt="" role="presentation">`, want: nil},
+		{name: "role none", body: `<img alt='' src='a.png' role='none'>`, want: nil},
+		{name: "role without alt", body: `<img src="a.png" role="presentation">`, want: []string{"a.png"}},
+		{name: "upper case", body: `<IMG SRC="a.png" ALT="A cat">`, want: nil},
+		{name: "several", body: `<p><img src="a.png" alt="A"><img src="b.png"><img src="c.png" alt=""></p>`, want: []string{"b.png", "c.png"}},
+		{name: "no images", body: `<p>Text</p>`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imagesWithoutAlt(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("imagesWithoutAlt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAltText(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		enabled bool
+		want    string
+	}{
+		{name: "missing alt", source: "![](https://example.com/cat.png)", enabled: true, want: "image https://example.com/cat.png has no alt text"},
+		{name: "alt text", source: "![A cat](https://example.com/cat.png)", enabled: true},
+		{name: "decorative", source: `![""](https://example.com/swirl.png)`, enabled: true},
+		{name: "disabled", source: "![](https://example.com/cat.png)", enabled: false},
+		{name: "featured image", source: "image: https://example.com/cat.png\n---\nText", enabled: true, want: "featured image https://example.com/cat.png has no image_alt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.source
+			if !strings.Contains(source, "---") {
+				source = "---\n" + source
+			}
+			withPosts(t, map[string]string{
+				"post.md": "title: Post\ndate: 2024-01-01T00:00:00Z\n" + source + "\n",
+			}, func(c *Config) { c.CheckAltText = tt.enabled })
+
+			var out bytes.Buffer
+			code := RunCheck(&out)
+			if tt.want == "" {
+				if code != 0 {
+					t.Errorf("the check failed: %s", out.String())
+				}
+				return
+			}
+			if code == 0 {
+				t.Error("the check passed")
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("output has no %q: %s", tt.want, out.String())
+			}
+		})
+	}
+}
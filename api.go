@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// APIPost is a post as returned by the API
+type APIPost struct {
+	Slug  string   `json:"slug"`
+	URL   string   `json:"url"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+	Date  string   `json:"date"`
+	Hash  string   `json:"hash"`
+}
+
+// PostsAPIHandler lists the published posts
+func PostsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	posts, err := GetAllPosts()
+	if err != nil {
+		log.Printf("Error getting all posts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	listed := ListedPosts(posts, time.Now())
+	apiPosts := make([]APIPost, 0, len(listed))
+	for _, post := range listed {
+		apiPosts = append(apiPosts, APIPost{
+			Slug:  post.Slug(),
+			URL:   postURL(post),
+			Title: post.Title,
+			Tags:  post.TagList(),
+			Date:  post.Date,
+			Hash:  post.ContentHash,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(apiPosts); err != nil {
+		log.Printf("Error encoding posts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// PostMeta is the front matter of a post, without the body
+type PostMeta struct {
+	Slug    string   `json:"slug"`
+	URL     string   `json:"url"`
+	Title   string   `json:"title"`
+	Date    string   `json:"date"`
+	Tags    []string `json:"tags"`
+	Draft   bool     `json:"draft"`
+	Summary string   `json:"summary,omitempty"`
+	Author  string   `json:"author,omitempty"`
+	Type    string   `json:"type"`
+	Lang    string   `json:"lang"`
+	Pinned  bool     `json:"pinned"`
+	Image   string   `json:"image,omitempty"`
+	Expires string   `json:"expires,omitempty"`
+	Hash    string   `json:"hash"`
+}
+
+// PostMetaHandler returns the front matter of a post as JSON. Like the post
+// itself, it's a 404 unless the post is published
+func PostMetaHandler(w http.ResponseWriter, r *http.Request) {
+	title := mux.Vars(r)["title"]
+
+	post, err := GetPost(title)
+	if os.IsNotExist(err) || (err == nil && !post.IsVisible(time.Now())) {
+		log.Printf("Post not found: %s", title)
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error getting post: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	writePostMeta(w, post)
+}
+
+// writePostMeta encodes the front matter of a post as the response
+func writePostMeta(w http.ResponseWriter, post Post) {
+	meta := PostMeta{
+		Slug:    post.Slug(),
+		URL:     postURL(post),
+		Title:   post.Title,
+		Date:    post.Date,
+		Tags:    post.TagList(),
+		Draft:   post.Draft,
+		Summary: post.Summary,
+		Author:  post.Author,
+		Type:    post.Type,
+		Lang:    post.Lang,
+		Pinned:  post.IsPinned(),
+		Image:   post.ImageURL(),
+		Expires: post.Expires,
+		Hash:    post.ContentHash,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(meta); err != nil {
+		log.Printf("Error encoding post: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
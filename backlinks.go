@@ -0,0 +1,66 @@
+package main
+
+import (
+	"html"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BuildBacklinks maps the slug of every post to the posts linking to it, in
+// the order of posts. Links are read off the rendered posts, wiki-links
+// included, resolved or not. Posts linking to themselves don't count, and
+// neither do several links to the same post
+func BuildBacklinks(posts []Post) map[string][]Post {
+	urls := map[string]string{}
+	slugs := map[string]bool{}
+	for _, post := range posts {
+		urls[strings.TrimSuffix(postURL(post), "/")] = post.Slug()
+		slugs[post.Slug()] = true
+	}
+
+	backlinks := map[string][]Post{}
+	for _, post := range posts {
+		seen := map[string]bool{post.Slug(): true}
+		for _, match := range hrefPattern.FindAllStringSubmatch(string(post.Body), -1) {
+			u, err := url.Parse(html.UnescapeString(match[1]))
+			if err != nil {
+				continue
+			}
+			var slug string
+			var ok bool
+			switch {
+			case u.Scheme == wikiScheme || u.Scheme == wikiTitleScheme:
+				slug, err = url.PathUnescape(u.Opaque)
+				ok = err == nil && slugs[slug]
+			case (u.Scheme == "" || u.Scheme == "http" || u.Scheme == "https") && isInternalLink(u):
+				slug, ok = linkTarget(urls, slugs, u.Path)
+			}
+			if !ok || seen[slug] {
+				continue
+			}
+			seen[slug] = true
+			backlinks[slug] = append(backlinks[slug], post)
+		}
+	}
+	return backlinks
+}
+
+// linkTarget returns the slug of the post a path on the site leads to, either
+// its URL or its /post/ one, which redirects there
+func linkTarget(urls map[string]string, slugs map[string]bool, path string) (string, bool) {
+	path = strings.TrimSuffix(path, "/")
+	if slug, ok := urls[path]; ok {
+		return slug, true
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) == 2 && parts[0] == "post" && slugs[trimPostExtension(parts[1])] {
+		return trimPostExtension(parts[1]), true
+	}
+	return "", false
+}
+
+// Backlinks returns the published posts linking to post
+func Backlinks(post Post, now time.Time) []Post {
+	return PublishedPosts(postCache.Backlinks(post.Slug()), now)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildBacklinks(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{name: "link", source: "See [target](/post/target).", want: []string{"linking.md"}},
+		{name: "link with extension", source: "See [target](/post/target.md).", want: []string{"linking.md"}},
+		{name: "absolute link", source: "See [target](http://example.com/post/target).", want: []string{"linking.md"}},
+		{name: "wiki-link", source: "See [[target]].", want: []string{"linking.md"}},
+		{name: "several links", source: "See [[target]] and [again](/post/target).", want: []string{"linking.md"}},
+		{name: "other site", source: "See [target](https://elsewhere.example/post/target).", want: nil},
+		{name: "missing post", source: "See [[missing]] and [this](/post/missing).", want: nil},
+		{name: "no links", source: "Nothing to see.", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{
+				"linking.md": "title: Linking\ndate: 2024-01-02T00:00:00Z\n---\n" + tt.source + "\n",
+				"target.md":  "title: Target\ndate: 2024-01-01T00:00:00Z\n---\nSee [myself](/post/target).\n",
+			}, func(c *Config) { c.BaseURL = "http://example.com" })
+
+			posts, err := GetAllPosts()
+			if err != nil {
+				t.Fatal(err)
+			}
+			backlinks := BuildBacklinks(posts)
+			if got := filenames(backlinks["target"]); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got backlinks %v, want %v", got, tt.want)
+			}
+			if got := backlinks["linking"]; len(got) != 0 {
+				t.Errorf("the linking post has backlinks %v", filenames(got))
+			}
+		})
+	}
+}
+
+func TestBacklinksSection(t *testing.T) {
+	withPosts(t, map[string]string{
+		"linking.md": "title: Linking\ndate: 2024-01-02T00:00:00Z\n---\nSee [[target]].\n",
+		"draft.md":   "title: Draft\ndate: 2024-01-03T00:00:00Z\ndraft: true\n---\nSee [[target]].\n",
+		"target.md":  "title: Target\ndate: 2024-01-01T00:00:00Z\n---\nTarget\n",
+	}, nil)
+	withTemplates(t)
+	if _, err := GetAllPosts(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(PostHandler, "/post/target", map[string]string{"title": "target"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d", rec.Code)
+	}
+	page := rec.Body.String()
+	if !strings.Contains(page, `class="backlinks"`) || !strings.Contains(page, `<a href="/post/linking">Linking</a>`) {
+		t.Errorf("the page has no backlink to the linking post: %s", page)
+	}
+	if strings.Contains(page, "/post/draft") {
+		t.Errorf("the page has a backlink to a draft: %s", page)
+	}
+
+	rec = serve(PostHandler, "/post/linking", map[string]string{"title": "linking"})
+	if strings.Contains(rec.Body.String(), `class="backlinks"`) {
+		t.Errorf("a post without backlinks has the section: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Post cache counters, published through expvar
+var (
+	postCacheHits   = expvar.NewInt("post_cache_hits")
+	postCacheMisses = expvar.NewInt("post_cache_misses")
+)
+
+// PostCache keeps the parsed posts, and whatever is derived from them, until
+// something in posts/ changes
+type PostCache struct {
+	mu    sync.Mutex
+	stamp string
+	posts []Post
+
+	searchIndex      []byte
+	searchValidUntil time.Time
+
+	// backlinks is BuildBacklinks of the posts, kept up to date with them
+	backlinks map[string][]Post
+}
+
+var postCache = &PostCache{}
+
+// postsStamp summarises names, sizes and modification times of the files, and
+// of the snippets in the includes directory, so that any change to the posts
+// or what they include results in a different stamp
+func postsStamp(files []string) (string, error) {
+	snippets, err := includeFiles()
+	if err != nil {
+		return "", err
+	}
+	return filesStamp(append(append([]string(nil), files...), snippets...))
+}
+
+// filesStamp summarises names, sizes and modification times of files so that
+// any change to them results in a different stamp
+func filesStamp(files []string) (string, error) {
+	var b strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// Get returns a copy of the cached posts if they were loaded with the same stamp
+func (c *PostCache) Get(stamp string) ([]Post, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.posts == nil || c.stamp != stamp {
+		postCacheMisses.Add(1)
+		return nil, false
+	}
+	postCacheHits.Add(1)
+
+	posts := make([]Post, len(c.posts))
+	copy(posts, c.posts)
+	return posts, true
+}
+
+// Set replaces the cached posts, dropping everything derived from the old
+// ones. It returns the posts it replaced, nil if nothing was cached yet
+func (c *PostCache) Set(stamp string, posts []Post) []Post {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if posts == nil {
+		posts = []Post{}
+	}
+	previous := c.posts
+	c.stamp = stamp
+	c.posts = posts
+	c.searchIndex = nil
+	c.backlinks = BuildBacklinks(posts)
+	return previous
+}
+
+// Replace swaps the cached post with the same filename for the given one,
+// dropping everything derived from the old one. It reports whether the post
+// was cached
+func (c *PostCache) Replace(post Post) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.posts {
+		if c.posts[i].Filename == post.Filename {
+			// Get hands out copies, so the slice can't be changed in place
+			posts := make([]Post, len(c.posts))
+			copy(posts, c.posts)
+			posts[i] = post
+			sortByDate(posts)
+			c.posts = posts
+			c.searchIndex = nil
+			c.backlinks = BuildBacklinks(posts)
+			return true
+		}
+	}
+	return false
+}
+
+// SearchIndex returns the cached search index if it's still valid at the given time
+func (c *PostCache) SearchIndex(stamp string, now time.Time) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.searchIndex == nil || c.stamp != stamp || !now.Before(c.searchValidUntil) {
+		return nil, false
+	}
+	return c.searchIndex, true
+}
+
+// SetSearchIndex caches the search index built from the posts with the given stamp
+func (c *PostCache) SetSearchIndex(stamp string, index []byte, validUntil time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stamp != stamp {
+		return
+	}
+	c.searchIndex = index
+	c.searchValidUntil = validUntil
+}
+
+// Backlinks returns the cached posts linking to the post with the given slug
+func (c *PostCache) Backlinks(slug string) []Post {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Post(nil), c.backlinks[slug]...)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+)
+
+// calloutTypes are the kinds of callout that get their own block, with their
+// titles. GitHub's five are there, plus danger
+var calloutTypes = map[string]string{
+	"note":      "Note",
+	"tip":       "Tip",
+	"important": "Important",
+	"warning":   "Warning",
+	"caution":   "Caution",
+	"danger":    "Danger",
+}
+
+// calloutMarker matches the [!TYPE] that starts a blockquote callout
+var calloutMarker = regexp.MustCompile(`^\[!(\w+)\][ \t]*\n?`)
+
+// calloutFence matches the opening line of a :::type block
+var calloutFence = regexp.MustCompile(`^:::\s*(\w+)\s*$`)
+
+// expandCallouts rewrites :::type blocks as blockquotes starting with [!TYPE]
+// so both syntaxes end up the same. Only the known types open a block, so a
+// lone ::: or an unknown type stays text, and code blocks are left alone
+func expandCallouts(source string) string {
+	var out strings.Builder
+	inCode, inCallout := false, false
+	for _, line := range strings.SplitAfter(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+		}
+
+		switch {
+		case inCallout && !inCode && trimmed == ":::":
+			inCallout = false
+			out.WriteString("\n")
+		case inCallout:
+			out.WriteString("> " + line)
+		case !inCode && calloutFence.MatchString(trimmed) && calloutTypes[strings.ToLower(calloutFence.FindStringSubmatch(trimmed)[1])] != "":
+			inCallout = true
+			kind := calloutFence.FindStringSubmatch(trimmed)[1]
+			out.WriteString(fmt.Sprintf("\n> [!%s]\n", strings.ToUpper(kind)))
+		default:
+			out.WriteString(line)
+		}
+	}
+	return out.String()
+}
+
+// renderCallouts finds the blockquotes starting with a known [!TYPE] marker,
+// strips the marker and tags them with the callout classes
+func renderCallouts(doc ast.Node) {
+	var quotes []*ast.BlockQuote
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if quote, ok := node.(*ast.BlockQuote); ok && entering {
+			quotes = append(quotes, quote)
+		}
+		return ast.GoToNext
+	})
+
+	for _, quote := range quotes {
+		for _, q := range splitCallouts(quote) {
+			markCallout(q)
+		}
+	}
+}
+
+// calloutKind returns the lowercase type of the marker starting node, if any
+func calloutKind(node ast.Node) (string, *ast.Text, int) {
+	para, ok := node.(*ast.Paragraph)
+	if !ok {
+		return "", nil, 0
+	}
+	text, ok := ast.GetFirstChild(para).(*ast.Text)
+	if !ok {
+		return "", nil, 0
+	}
+	m := calloutMarker.FindSubmatch(text.Literal)
+	if m == nil {
+		return "", nil, 0
+	}
+	return strings.ToLower(string(m[1])), text, len(m[0])
+}
+
+// splitCallouts splits a blockquote before every marker but the first one,
+// known or not.
+// Markdown merges quotes only separated by blank lines, which would turn
+// consecutive callouts into one
+func splitCallouts(quote *ast.BlockQuote) []*ast.BlockQuote {
+	quotes := []*ast.BlockQuote{quote}
+	children := quote.Children
+	start := 0
+	var groups [][]ast.Node
+	for i, child := range children {
+		if kind, _, _ := calloutKind(child); i > 0 && kind != "" {
+			groups = append(groups, children[start:i])
+			start = i
+		}
+	}
+	if len(groups) == 0 {
+		return quotes
+	}
+	groups = append(groups, children[start:])
+
+	parent := quote.Parent.AsContainer()
+	pos := 0
+	for i, sibling := range parent.Children {
+		if sibling == quote {
+			pos = i
+			break
+		}
+	}
+
+	quote.Children = groups[0]
+	var added []ast.Node
+	for _, group := range groups[1:] {
+		q := &ast.BlockQuote{}
+		q.Parent = quote.Parent
+		q.Children = group
+		for _, child := range group {
+			child.SetParent(q)
+		}
+		quotes = append(quotes, q)
+		added = append(added, q)
+	}
+
+	rest := append(added, parent.Children[pos+1:]...)
+	parent.Children = append(parent.Children[:pos+1:pos+1], rest...)
+	return quotes
+}
+
+// markCallout strips the marker of a callout and tags it with its classes
+func markCallout(quote *ast.BlockQuote) {
+	kind, text, n := calloutKind(ast.GetFirstChild(quote))
+	if calloutTypes[kind] == "" {
+		return
+	}
+
+	text.Literal = text.Literal[n:]
+	if para := text.Parent; len(para.GetChildren()) == 1 && len(bytes.TrimSpace(text.Literal)) == 0 {
+		ast.RemoveFromTree(para)
+	}
+
+	quote.Attribute = &ast.Attribute{
+		Classes: [][]byte{
+			[]byte("callout"), []byte("callout-" + kind),
+			[]byte("admonition"), []byte("admonition-" + kind),
+		},
+	}
+}
+
+// renderCalloutNode is the render hook writing the tagged blockquotes as divs
+func renderCalloutNode(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	quote, ok := node.(*ast.BlockQuote)
+	if !ok || quote.Attribute == nil || !isCallout(quote.Attribute) {
+		return ast.GoToNext, false
+	}
+
+	if entering {
+		io.WriteString(w, "\n"+html.TagWithAttributes("<div", html.BlockAttrs(quote))+"\n")
+		fmt.Fprintf(w, "<p class=\"callout-title\">%s</p>\n", calloutTypes[calloutKindOf(quote.Attribute)])
+	} else {
+		io.WriteString(w, "</div>\n")
+	}
+	return ast.GoToNext, true
+}
+
+// calloutKindOf returns the type of a block tagged as a callout
+func calloutKindOf(attr *ast.Attribute) string {
+	for _, class := range attr.Classes {
+		if kind, ok := strings.CutPrefix(string(class), "callout-"); ok {
+			return kind
+		}
+	}
+	return ""
+}
+
+// isCallout reports whether a block has been tagged as a callout
+func isCallout(attr *ast.Attribute) bool {
+	for _, class := range attr.Classes {
+		if string(class) == "callout" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// renderBody renders Markdown as the body of a post with the current configuration
+func renderBody(t *testing.T, source string) string {
+	t.Helper()
+
+	post, err := parsePostBytes("post.md", []byte("title: Post\n---\n"+source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(post.Body)
+}
+
+func TestFencedCallouts(t *testing.T) {
+	withConfig(t, nil)
+
+	for kind, title := range calloutTypes {
+		t.Run(kind, func(t *testing.T) {
+			body := renderBody(t, ":::"+kind+"\nMind the *gap*.\n:::\n")
+
+			want := `<div class="callout callout-` + kind + ` admonition admonition-` + kind + `">` + "\n" +
+				`<p class="callout-title">` + title + "</p>\n<p>Mind the <em>gap</em>.</p>\n</div>"
+			if !strings.Contains(body, want) {
+				t.Errorf("got %s, want %s", body, want)
+			}
+			if strings.Contains(body, "<blockquote") || strings.Contains(body, ":::") {
+				t.Errorf("leftovers of the callout in %s", body)
+			}
+		})
+	}
+}
+
+func TestFencedCalloutsLeftAlone(t *testing.T) {
+	withConfig(t, nil)
+
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		notWant string
+	}{
+		{name: "unknown type", source: ":::spoiler\nText\n:::\n", want: ":::spoiler", notWant: "callout"},
+		{name: "lone fence", source: "Text\n\n:::\n\nMore text\n", want: "<p>:::</p>", notWant: "callout"},
+		{name: "in code", source: "```\n:::note\nText\n:::\n```\n", want: ":::note\nText\n:::", notWant: "callout"},
+		{name: "uppercase type", source: ":::NOTE\nText\n:::\n", want: `class="callout callout-note`, notWant: ":::"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := renderBody(t, tt.source)
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("body has no %q: %s", tt.want, body)
+			}
+			if strings.Contains(body, tt.notWant) {
+				t.Errorf("body has %q: %s", tt.notWant, body)
+			}
+		})
+	}
+}
+
+func TestBlockquoteCallouts(t *testing.T) {
+	withConfig(t, nil)
+
+	tests := []struct {
+		name    string
+		source  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "warning",
+			source:  "> [!WARNING]\n> Hot surface.\n",
+			want:    []string{`<div class="callout callout-warning admonition admonition-warning">`, `<p class="callout-title">Warning</p>`, "<p>Hot surface.</p>"},
+			notWant: []string{"[!WARNING]", "<blockquote"},
+		},
+		{
+			name:    "text on the marker line",
+			source:  "> [!NOTE] Read this.\n",
+			want:    []string{`class="callout callout-note`, "<p>Read this.</p>"},
+			notWant: []string{"[!NOTE]"},
+		},
+		{
+			name:   "consecutive callouts",
+			source: "> [!TIP]\n> One.\n\n> [!CAUTION]\n> Two.\n",
+			want:   []string{`class="callout callout-tip`, `class="callout callout-caution`},
+		},
+		{
+			name:    "unknown type",
+			source:  "> [!RANT]\n> Grr.\n",
+			want:    []string{"<blockquote>", "[!RANT]"},
+			notWant: []string{"callout"},
+		},
+		{
+			name:    "plain blockquote",
+			source:  "> Just a quote.\n",
+			want:    []string{"<blockquote>\n<p>Just a quote.</p>\n</blockquote>"},
+			notWant: []string{"callout"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := renderBody(t, tt.source)
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body has no %q: %s", want, body)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(body, notWant) {
+					t.Errorf("body has %q: %s", notWant, body)
+				}
+			}
+		})
+	}
+}
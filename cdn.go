@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// cdnURL returns the URL of a static file on the CDN, if there's one.
+// Anything outside of /static/ is returned as it is
+func cdnURL(path string) string {
+	if config.CDNURL == "" || !strings.HasPrefix(path, "/static/") {
+		return path
+	}
+	return strings.TrimSuffix(config.CDNURL, "/") + path
+}
+
+// rewriteImages points the images of a post under /static/ to the CDN
+func rewriteImages(doc ast.Node) {
+	if config.CDNURL == "" {
+		return
+	}
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if image, ok := node.(*ast.Image); ok && entering {
+			image.Destination = []byte(cdnURL(string(image.Destination)))
+		}
+		return ast.GoToNext
+	})
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCDNImages(t *testing.T) {
+	tests := []struct {
+		name   string
+		cdn    string
+		source string
+		want   string
+	}{
+		{name: "local image", cdn: "https://cdn.example.com", source: "![Cat](/static/img/cat.png)", want: `src="https://cdn.example.com/static/img/cat.png"`},
+		{name: "trailing slash on the CDN", cdn: "https://cdn.example.com/", source: "![Cat](/static/img/cat.png)", want: `src="https://cdn.example.com/static/img/cat.png"`},
+		{name: "no CDN", cdn: "", source: "![Cat](/static/img/cat.png)", want: `src="/static/img/cat.png"`},
+		{name: "external image", cdn: "https://cdn.example.com", source: "![Cat](https://cats.example.com/cat.png)", want: `src="https://cats.example.com/cat.png"`},
+		{name: "outside static", cdn: "https://cdn.example.com", source: "![Cat](/img/cat.png)", want: `src="/img/cat.png"`},
+		{name: "links stay", cdn: "https://cdn.example.com", source: "[Cat](/static/img/cat.png)", want: `href="/static/img/cat.png"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.CDNURL = tt.cdn })
+			if body := renderBody(t, tt.source+"\n"); !strings.Contains(body, tt.want) {
+				t.Errorf("body has no %s: %s", tt.want, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Problem is something wrong found while validating the site
+type Problem struct {
+	File    string
+	Message string
+}
+
+// staticRefPattern matches links and images pointing at static files
+var staticRefPattern = regexp.MustCompile(`(?:src|href)="(/static/[^"#?]+)`)
+
+// Validate parses every post and template the way the server would and
+// returns all the problems found, without starting anything
+func Validate() []Problem {
+	var problems []Problem
+	add := func(file string, format string, args ...interface{}) {
+		problems = append(problems, Problem{file, fmt.Sprintf(format, args...)})
+	}
+
+	for name, files := range templateFiles {
+		if _, err := parseTemplates(name); err != nil {
+			add(strings.Join(files, ", "), "template doesn't parse: %v", err)
+		}
+	}
+
+	files, err := globPosts()
+	if err != nil {
+		add(strings.Join(config.ContentDirs, ", "), "can't list posts: %v", err)
+		return problems
+	}
+
+	slugs := map[string]string{}
+	for _, file := range files {
+		post, err := parsePostFile(file)
+		if err != nil {
+			add(file, "%v", err)
+			continue
+		}
+		post.Filename = filepath.Base(file)
+
+		if strings.TrimSpace(post.Title) == "" {
+			add(file, "missing title")
+		}
+		if _, err := time.Parse(time.RFC3339, post.Date); err != nil {
+			add(file, "date %q isn't in RFC3339 format (or 2006-01-02 15:04:05 without a zone)", post.Date)
+		}
+		if post.Expires != "" {
+			if _, err := time.Parse(time.RFC3339, post.Expires); err != nil {
+				add(file, "expiry date %q isn't in RFC3339 format", post.Expires)
+			}
+		}
+
+		// Slugs end up in URLs, where case doesn't help telling them apart
+		slug := strings.ToLower(post.Slug())
+		if other, ok := slugs[slug]; ok {
+			add(file, "slug %q is also used by %s", post.Slug(), other)
+		} else {
+			slugs[slug] = file
+		}
+		if post.Type == TypePage && reservedSlugs[post.Slug()] {
+			add(file, "page %q is shadowed by a built-in route", post.Slug())
+		}
+
+		body := string(post.Body)
+		if config.CDNURL != "" {
+			// Images on the CDN are still the files under static/
+			body = strings.ReplaceAll(body, `"`+cdnURL("/static/"), `"/static/`)
+		}
+		refs := staticRefPattern.FindAllStringSubmatch(body, -1)
+		if strings.HasPrefix(post.ImageURL(), "/static/") {
+			refs = append(refs, []string{"", post.ImageURL()})
+		}
+		for _, ref := range refs {
+			path, err := url.PathUnescape(ref[1])
+			if err != nil {
+				path = ref[1]
+			}
+			if _, err := os.Stat(filepath.Join("static", strings.TrimPrefix(path, "/static/"))); err != nil {
+				add(file, "%s doesn't exist", ref[1])
+			}
+		}
+
+		if config.CheckAltText {
+			for _, src := range imagesWithoutAlt(body) {
+				add(file, "image %s has no alt text", src)
+			}
+			if post.Image != "" && strings.TrimSpace(post.ImageAlt) == "" {
+				add(file, "featured image %s has no image_alt", post.Image)
+			}
+		}
+	}
+
+	return problems
+}
+
+// RunCheck validates the site, prints a summary of the problems per file
+// and returns the exit code
+func RunCheck(w io.Writer) int {
+	return reportProblems(w, Validate())
+}
+
+// reportProblems prints a summary of the problems per file and returns the
+// exit code, 1 if there's any
+func reportProblems(w io.Writer, problems []Problem) int {
+	if len(problems) == 0 {
+		fmt.Fprintln(w, "Everything looks fine")
+		return 0
+	}
+
+	byFile := map[string][]string{}
+	var files []string
+	for _, p := range problems {
+		if _, ok := byFile[p.File]; !ok {
+			files = append(files, p.File)
+		}
+		byFile[p.File] = append(byFile[p.File], p.Message)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		fmt.Fprintf(w, "%s:\n", file)
+		for _, msg := range byFile[file] {
+			fmt.Fprintf(w, "  - %s\n", msg)
+		}
+	}
+	fmt.Fprintf(w, "%d problem(s) in %d file(s)\n", len(problems), len(files))
+	return 1
+}
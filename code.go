@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// fenceAttributes matches an opening fence with attributes after the language,
+// like ```go {hl_lines=[2,3]}
+var fenceAttributes = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^\\s{}]+)[ \t]*\\{([^}\\n]*)\\}[ \t]*$")
+
+// moveFenceAttributes rewrites ```go {opts} as ```{go opts}, the only form
+// of fence info with spaces the Markdown parser understands
+func moveFenceAttributes(source string) string {
+	var out strings.Builder
+	var fence string
+	for _, line := range strings.SplitAfter(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			out.WriteString(line)
+			continue
+		}
+
+		if m := fenceAttributes.FindStringSubmatch(strings.TrimRight(line, "\r\n")); m != nil {
+			fence = m[2]
+			out.WriteString(fmt.Sprintf("%s%s{%s %s}", m[1], m[2], m[3], m[4]))
+			out.WriteString(line[len(strings.TrimRight(line, "\r\n")):])
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, "`~"))]
+		}
+		out.WriteString(line)
+	}
+	return out.String()
+}
+
+// codeLines splits the text of a code block into its lines
+func codeLines(literal []byte) []string {
+	return strings.Split(strings.TrimSuffix(string(literal), "\n"), "\n")
+}
+
+// parseLineRanges parses hl_lines values like [2,3] or "1-3 7" for a code
+// block of n lines. Lines past the end of the block are left out, so a huge
+// range costs no more than the block itself
+func parseLineRanges(value string, n int) (map[int]bool, error) {
+	lines := map[int]bool{}
+	value = strings.Trim(value, `[]"'`)
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		from, to, isRange := strings.Cut(field, "-")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line %q", field)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(to); err != nil || end < start {
+				return nil, fmt.Errorf("invalid line range %q", field)
+			}
+		}
+		if start < 1 {
+			start = 1
+		}
+		if end > n {
+			end = n
+		}
+		for i := start; i <= end; i++ {
+			lines[i] = true
+		}
+	}
+	return lines, nil
+}
+
+// renderCodeOptions reads the options in the info of the code blocks,
+// hl_lines and linenos, leaving only the language there. Blocks that end up
+// with highlighted lines or line numbers are tagged for renderCodeNode
+func renderCodeOptions(doc ast.Node) error {
+	var err error
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		code, ok := node.(*ast.CodeBlock)
+		if !ok || isMermaid(node) {
+			return ast.GoToNext
+		}
+
+		fields := strings.Fields(string(code.Info))
+		lang := ""
+		if len(fields) > 0 && !strings.Contains(fields[0], "=") {
+			lang, fields = fields[0], fields[1:]
+		}
+
+		linenos := config.CodeLineNumbers
+		var hl map[int]bool
+		for _, field := range fields {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "hl_lines":
+				if hl, err = parseLineRanges(value, len(codeLines(code.Literal))); err != nil {
+					return ast.Terminate
+				}
+			case "linenos":
+				linenos = value == "true" || value == "table" || value == "inline"
+			}
+		}
+
+		code.Info = []byte(lang)
+		if linenos || len(hl) > 0 {
+			attrs := map[string][]byte{}
+			if linenos {
+				attrs["linenos"] = []byte("true")
+			}
+			var lines []string
+			for line := range hl {
+				lines = append(lines, strconv.Itoa(line))
+			}
+			attrs["hl_lines"] = []byte(strings.Join(lines, ","))
+			code.Attribute = &ast.Attribute{Attrs: attrs}
+		}
+		return ast.GoToNext
+	})
+	return err
+}
+
+// renderCodeNode is the render hook writing code blocks line by line, with
+// line numbers and highlighted lines
+func renderCodeNode(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	code, ok := node.(*ast.CodeBlock)
+	if !ok || code.Attribute == nil || code.Attrs == nil {
+		return ast.GoToNext, false
+	}
+
+	lines := codeLines(code.Literal)
+	hl, _ := parseLineRanges(string(code.Attrs["hl_lines"]), len(lines))
+	linenos := string(code.Attrs["linenos"]) == "true"
+
+	io.WriteString(w, "\n<pre>")
+	if len(code.Info) > 0 {
+		io.WriteString(w, `<code class="language-`+html.EscapeString(string(code.Info))+`">`)
+	} else {
+		io.WriteString(w, "<code>")
+	}
+	for i, line := range lines {
+		class := "line"
+		if hl[i+1] {
+			class += " hl"
+		}
+		io.WriteString(w, `<span class="`+class+`">`)
+		if linenos {
+			io.WriteString(w, fmt.Sprintf(`<span class="ln">%d</span>`, i+1))
+		}
+		io.WriteString(w, html.EscapeString(line)+"</span>")
+	}
+	io.WriteString(w, "</code></pre>\n")
+	return ast.GoToNext, true
+}
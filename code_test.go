@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLineRanges(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[int]bool
+		wantErr bool
+	}{
+		{value: "[2,3]", want: map[int]bool{2: true, 3: true}},
+		{value: `"1-3 7"`, want: map[int]bool{1: true, 2: true, 3: true, 7: true}},
+		{value: "", want: map[int]bool{}},
+		{value: "[0-2]", want: map[int]bool{1: true, 2: true}},
+		{value: "[9-1000000000]", want: map[int]bool{9: true, 10: true}},
+		{value: "[11-1000000000]", want: map[int]bool{}},
+		{value: "[2,1000000000]", want: map[int]bool{2: true}},
+		{value: "[3-1]", wantErr: true},
+		{value: "[two]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			// As for a block of 10 lines
+			got, err := parseLineRanges(tt.value, 10)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightedLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		linenos bool
+		source  string
+		want    string
+	}{
+		{
+			name:   "highlighted lines",
+			source: "```go {hl_lines=[2,3]}\na := 1\nb := 2\nc := 3\nd := 4\n```\n",
+			want: `<pre><code class="language-go">` +
+				`<span class="line">a := 1</span>` +
+				`<span class="line hl">b := 2</span>` +
+				`<span class="line hl">c := 3</span>` +
+				`<span class="line">d := 4</span></code></pre>`,
+		},
+		{
+			name:   "line numbers in the fence",
+			source: "```go {linenos=true hl_lines=\"1\"}\na := 1\nb := 2\n```\n",
+			want: `<pre><code class="language-go">` +
+				`<span class="line hl"><span class="ln">1</span>a := 1</span>` +
+				`<span class="line"><span class="ln">2</span>b := 2</span></code></pre>`,
+		},
+		{
+			name:    "line numbers configured",
+			linenos: true,
+			source:  "```\n<b>\n```\n",
+			want:    `<pre><code><span class="line"><span class="ln">1</span>&lt;b&gt;</span></code></pre>`,
+		},
+		{
+			name:   "range past the end",
+			source: "```go {hl_lines=[2-1000000000]}\na := 1\nb := 2\n```\n",
+			want: `<pre><code class="language-go">` +
+				`<span class="line">a := 1</span>` +
+				`<span class="line hl">b := 2</span></code></pre>`,
+		},
+		{
+			name:   "no options",
+			source: "```go\na := 1\n```\n",
+			want:   "<pre><code class=\"language-go\">a := 1\n</code></pre>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.CodeLineNumbers = tt.linenos })
+			if body := renderBody(t, tt.source); !strings.Contains(body, tt.want) {
+				t.Errorf("body has no %s:\n%s", tt.want, body)
+			}
+		})
+	}
+}
+
+func TestInvalidLineRange(t *testing.T) {
+	withConfig(t, nil)
+
+	_, err := parsePostBytes("post.md", []byte("title: Post\n---\n```go {hl_lines=[x]}\na := 1\n```\n"))
+	if err == nil {
+		t.Error("no error for an invalid hl_lines")
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config holds the site configuration
+type Config struct {
+	Title          string `yaml:"title"`
+	Description    string `yaml:"description"`
+	BaseURL        string `yaml:"base_url"`
+	CanonicalHost  string `yaml:"canonical_host"`
+	ForceHTTPS     bool   `yaml:"force_https"`
+	Language       string `yaml:"language"`
+	IconsDir       string `yaml:"icons_dir"`
+	Favicon        string `yaml:"favicon"`
+	ThemeColor     string `yaml:"theme_color"`
+	Background     string `yaml:"background_color"`
+	Sort           string `yaml:"sort"`
+	DateFormat     string `yaml:"date_format"`
+	EmptyMessage   string `yaml:"empty_message"`
+	PrettyURLs     bool   `yaml:"pretty_urls"`
+	Permalink      string `yaml:"permalink"`
+	MermaidURL     string `yaml:"mermaid_url"`
+	CDNURL         string `yaml:"cdn_url"`
+	License        string `yaml:"license"`
+	DraftWatermark string `yaml:"draft_watermark"`
+	FeedMode       string `yaml:"feed_mode"`
+	SiteName       string `yaml:"site_name"`
+	OGImage        string `yaml:"og_image"`
+	Twitter        string `yaml:"twitter"`
+
+	DescriptionLength int `yaml:"description_length"`
+	SitemapSize       int `yaml:"sitemap_size"`
+	RecentPosts       int `yaml:"recent_posts"`
+	RelatedPosts      int `yaml:"related_posts"`
+	UpdatedPosts      int `yaml:"updated_posts"`
+	WordsPerMinute    int `yaml:"words_per_minute"`
+
+	// Timezone is the name of the site's timezone, e.g. "Europe/Rome", and
+	// Location the zone itself once loaded
+	Timezone string         `yaml:"timezone"`
+	Location *time.Location `yaml:"-"`
+
+	ContentDirs        []string `yaml:"content_dirs"`
+	DraftsDir          string   `yaml:"drafts_dir"`
+	IndexFile          string   `yaml:"index_file"`
+	PostExtensions     []string `yaml:"post_extensions"`
+	MarkdownExtensions []string `yaml:"markdown_extensions"`
+	CodeLineNumbers    bool     `yaml:"code_line_numbers"`
+	HeadingOffset      int      `yaml:"heading_offset"`
+	SmartTypography    bool     `yaml:"smart_typography"`
+	Emoji              bool     `yaml:"emoji"`
+
+	UpdatedThreshold time.Duration `yaml:"updated_threshold"`
+	SuggestDistance  int           `yaml:"suggest_distance"`
+
+	RenderCacheSize int   `yaml:"render_cache_size"`
+	ParseWorkers    int   `yaml:"parse_workers"`
+	StrictParsing   bool  `yaml:"strict_parsing"`
+	MaxPostSize     int64 `yaml:"max_post_size"`
+	CheckAltText    bool  `yaml:"check_alt_text"`
+
+	Locales      []string                     `yaml:"locales"`
+	Translations map[string]map[string]string `yaml:"translations"`
+
+	Sitemap         SitemapConfig         `yaml:"sitemap"`
+	TLS             TLSConfig             `yaml:"tls"`
+	CORS            CORSConfig            `yaml:"cors"`
+	SecurityHeaders SecurityHeadersConfig `yaml:"security_headers"`
+	Comments        CommentsConfig        `yaml:"comments"`
+	Analytics       AnalyticsConfig       `yaml:"analytics"`
+	Admin           AdminConfig           `yaml:"admin"`
+	Webhooks        WebhookConfig         `yaml:"webhooks"`
+}
+
+// CommentsConfig sets up the embed of a hosted comments system on post pages.
+// Comments are off unless a script URL is set
+type CommentsConfig struct {
+	Provider   string            `yaml:"provider"`
+	ScriptURL  string            `yaml:"script_url"`
+	Attributes map[string]string `yaml:"attributes"`
+}
+
+// AnalyticsConfig adds the snippet of a hosted analytics service to every page.
+// Analytics are off unless a script URL is set, and in dev mode unless Dev is
+type AnalyticsConfig struct {
+	Provider   string `yaml:"provider"`
+	ScriptURL  string `yaml:"script_url"`
+	SiteID     string `yaml:"site_id"`
+	Dev        bool   `yaml:"dev"`
+	SkipDrafts bool   `yaml:"skip_drafts"`
+}
+
+// expandConfig expands the environment variables in every string of a YAML
+// document. Working on the parsed document rather than on the text means the
+// values of the variables can't mess with the YAML syntax
+func expandConfig(content []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		// An empty file, which would come back as null and wipe the defaults
+		return content, nil
+	}
+
+	var missing []string
+	doc = expandValue(doc, &missing)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("environment variables used in the configuration are not set: %s", strings.Join(missing, ", "))
+	}
+
+	return yaml.Marshal(doc)
+}
+
+// envReference matches ${NAME}, and $${NAME}, its escaped form
+var envReference = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandValue expands the environment variables in the strings of a YAML value,
+// collecting the names of the ones that aren't set. Only ${NAME} is a variable,
+// so passwords and the like can have a $ anywhere else, and $${NAME} is a
+// literal ${NAME}
+func expandValue(v interface{}, missing *[]string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return envReference.ReplaceAllStringFunc(v, func(ref string) string {
+			if strings.HasPrefix(ref, "$$") {
+				return ref[1:]
+			}
+			name := envReference.FindStringSubmatch(ref)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				*missing = append(*missing, name)
+			}
+			return value
+		})
+	case []interface{}:
+		for i := range v {
+			v[i] = expandValue(v[i], missing)
+		}
+	case map[interface{}]interface{}:
+		for k := range v {
+			v[k] = expandValue(v[k], missing)
+		}
+	}
+	return v
+}
+
+var dataAttributePattern = regexp.MustCompile(`^data-[a-z0-9-]+$`)
+
+// DataAttributes renders the configured attributes for the embed script.
+// Only data-* attributes are allowed, anything else is dropped
+func (c CommentsConfig) DataAttributes() template.HTMLAttr {
+	names := make([]string, 0, len(c.Attributes))
+	for name := range c.Attributes {
+		if dataAttributePattern.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, ` %s="%s"`, name, html.EscapeString(c.Attributes[name]))
+	}
+	return template.HTMLAttr(b.String())
+}
+
+// analyticsFor returns the analytics to add to a page, nil for none. draft
+// tells whether the page shows a draft, which only happens in dev mode
+func analyticsFor(draft bool) *AnalyticsConfig {
+	a := config.Analytics
+	if a.ScriptURL == "" || (devMode && !a.Dev) || (draft && a.SkipDrafts) {
+		return nil
+	}
+	return &a
+}
+
+// location returns the timezone dates without one are in, UTC unless the site
+// has a timezone
+func (c Config) location() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+	return c.Location
+}
+
+// Sort directions for the index
+const (
+	SortDesc = "desc"
+	SortAsc  = "asc"
+)
+
+// config is the configuration in use, the defaults until LoadConfig is called
+var config = DefaultConfig()
+
+// DefaultConfig returns the configuration used when there's no config file
+func DefaultConfig() Config {
+	return Config{
+		Title:          "io.",
+		Description:    "io.myyc.dev",
+		BaseURL:        "http://io.myyc.dev",
+		Language:       "en-gb",
+		IconsDir:       "static/icons",
+		ThemeColor:     "#130205",
+		Background:     "#F0E1CE",
+		Sort:           SortDesc,
+		DateFormat:     "2006-01-02",
+		EmptyMessage:   "Nothing here yet.",
+		PrettyURLs:     true,
+		MermaidURL:     "https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.esm.min.mjs",
+		DraftWatermark: "DRAFT",
+		FeedMode:       FeedParagraphs,
+
+		DescriptionLength: 160,
+		SitemapSize:       50000,
+		RecentPosts:       5,
+		RelatedPosts:      3,
+		WordsPerMinute:    200,
+
+		ContentDirs:        []string{"posts"},
+		IndexFile:          "index.md",
+		PostExtensions:     []string{".md", ".markdown"},
+		MarkdownExtensions: defaultMarkdownExtensions,
+		SmartTypography:    true,
+		Emoji:              true,
+
+		UpdatedThreshold: time.Hour,
+		SuggestDistance:  3,
+
+		RenderCacheSize: 256,
+		MaxPostSize:     10 << 20,
+		CheckAltText:    true,
+
+		Sitemap: SitemapConfig{
+			Home:      SitemapEntryConfig{ChangeFreq: "daily", Priority: 1.0},
+			Posts:     SitemapEntryConfig{ChangeFreq: "monthly", Priority: 0.8},
+			Pages:     SitemapEntryConfig{ChangeFreq: "yearly", Priority: 0.5},
+			TagsEntry: SitemapEntryConfig{ChangeFreq: "weekly", Priority: 0.3},
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "OPTIONS"},
+		},
+		TLS: TLSConfig{
+			CacheDir: "cache/autocert",
+			HTTPAddr: ":80",
+		},
+		SecurityHeaders: SecurityHeadersConfig{
+			ContentSecurityPolicy: CSPAuto,
+			ReferrerPolicy:        "strict-origin-when-cross-origin",
+			FrameOptions:          "DENY",
+		},
+		Analytics: AnalyticsConfig{
+			SkipDrafts: true,
+		},
+		Webhooks: WebhookConfig{
+			Timeout: 10 * time.Second,
+			Retries: 3,
+		},
+	}
+}
+
+// LoadConfig reads a YAML config file. Missing fields keep their default
+// value, and ${VAR} in any string is replaced with the environment
+// variable, which has to be set
+func LoadConfig(filename string) (Config, error) {
+	c := DefaultConfig()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return c, err
+	}
+
+	content, err = expandConfig(content)
+	if err != nil {
+		return c, err
+	}
+
+	if err := yaml.Unmarshal(content, &c); err != nil {
+		return c, err
+	}
+
+	if c.Sort != SortDesc && c.Sort != SortAsc {
+		return c, fmt.Errorf("invalid sort direction: %s", c.Sort)
+	}
+	switch c.FeedMode {
+	case FeedParagraphs, FeedFull, FeedSummary, FeedExcerpt:
+	default:
+		return c, fmt.Errorf("invalid feed mode: %s", c.FeedMode)
+	}
+	if c.Twitter != "" && !strings.HasPrefix(c.Twitter, "@") {
+		c.Twitter = "@" + c.Twitter
+	}
+	if len(c.ContentDirs) == 0 {
+		return c, fmt.Errorf("content_dirs can't be empty")
+	}
+	for _, dir := range c.ContentDirs {
+		if c.DraftsDir != "" && filepath.Clean(dir) == filepath.Clean(c.DraftsDir) {
+			return c, fmt.Errorf("drafts_dir %s can't be one of content_dirs", c.DraftsDir)
+		}
+	}
+	if c.TLS.Enabled() && c.TLS.CacheDir == "" {
+		return c, fmt.Errorf("tls.cache_dir can't be empty, certificates would be requested again on every start")
+	}
+	if len(c.PostExtensions) == 0 {
+		return c, fmt.Errorf("post_extensions can't be empty")
+	}
+	for _, ext := range c.PostExtensions {
+		if !strings.HasPrefix(ext, ".") || strings.ContainsAny(ext, `/\*?[`) {
+			return c, fmt.Errorf("invalid post extension: %q", ext)
+		}
+	}
+	if c.RecentPosts < 0 || c.RelatedPosts < 0 || c.UpdatedPosts < 0 {
+		return c, fmt.Errorf("recent_posts, related_posts and updated_posts can't be negative")
+	}
+	if c.HeadingOffset < 0 {
+		return c, fmt.Errorf("heading_offset can't be negative")
+	}
+	if c.WordsPerMinute <= 0 {
+		return c, fmt.Errorf("words_per_minute must be positive")
+	}
+	if c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return c, fmt.Errorf("invalid timezone: %w", err)
+		}
+		c.Location = loc
+	}
+	if err := c.Sitemap.validate(); err != nil {
+		return c, err
+	}
+	if err := validatePermalink(c.Permalink); err != nil {
+		return c, err
+	}
+	if err := validateDateFormat(c.DateFormat); err != nil {
+		return c, err
+	}
+	if _, err := ParseMarkdownExtensions(c.MarkdownExtensions); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// validateDateFormat formats a sample time with a Go time layout. A layout
+// without any of the reference values comes back unchanged, which is never
+// what was meant
+func validateDateFormat(layout string) error {
+	sample := time.Date(2009, time.November, 10, 23, 4, 5, 0, time.UTC)
+	if layout == "" || sample.Format(layout) == layout {
+		return fmt.Errorf("invalid date format %q, expected a layout like \"2006-01-02\"", layout)
+	}
+	return nil
+}
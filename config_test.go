@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigEnvironment(t *testing.T) {
+	t.Setenv("IO_TEST_PASSWORD", "s3cret")
+	t.Setenv("IO_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "variable", value: "${IO_TEST_PASSWORD}", want: "s3cret"},
+		{name: "inside a string", value: "pre-${IO_TEST_PASSWORD}-post", want: "pre-s3cret-post"},
+		{name: "empty variable", value: "${IO_TEST_EMPTY}", want: ""},
+		{name: "dollar", value: "pa$word", want: "pa$word"},
+		{name: "dollar and digit", value: "costs $5", want: "costs $5"},
+		{name: "bare name", value: "$IO_TEST_PASSWORD", want: "$IO_TEST_PASSWORD"},
+		{name: "double dollar", value: "a$$b", want: "a$$b"},
+		{name: "escaped", value: "$${IO_TEST_PASSWORD}", want: "${IO_TEST_PASSWORD}"},
+		{name: "not a name", value: "${1}", want: "${1}"},
+		{name: "csp", value: "script-src 'self' 'nonce-$x'", want: "script-src 'self' 'nonce-$x'"},
+		{name: "unset", value: "${IO_TEST_UNSET}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"config.yaml": "title: \"" + tt.value + "\"\n"})
+
+			c, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("no error, got title %q", c.Title)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Title != tt.want {
+				t.Errorf("got %q, want %q", c.Title, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmptyConfig(t *testing.T) {
+	for _, content := range []string{"", "\n", "# nothing yet\n"} {
+		dir := writeFiles(t, map[string]string{"config.yaml": content})
+
+		c, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+		if err != nil {
+			t.Errorf("%q: %v", content, err)
+			continue
+		}
+		if want := DefaultConfig(); c.Title != want.Title || c.Sort != want.Sort || c.WordsPerMinute != want.WordsPerMinute {
+			t.Errorf("%q: the defaults are gone: %+v", content, c)
+		}
+	}
+}
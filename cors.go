@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CORSConfig lists who can call the API from another origin. CORS is
+// disabled, i.e. the API is same-origin only, when no origins are allowed
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	AllowedMethods []string `yaml:"allowed_methods"`
+	AllowedHeaders []string `yaml:"allowed_headers"`
+}
+
+// allowsOrigin reports whether origin is in the allowed list, "*" allowing any
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS adds the CORS headers for allowed origins and answers preflight requests
+func CORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := config.CORS
+		if len(c.AllowedOrigins) > 0 {
+			// The answer depends on the origin whether it's allowed or not,
+			// and caches must not hand one origin's answer to another
+			w.Header().Add("Vary", "Origin")
+		}
+		origin := r.Header.Get("Origin")
+		if origin != "" && c.allowsOrigin(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if r.Method == http.MethodOptions {
+				w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.AllowedMethods, ", "))
+				if len(c.AllowedHeaders) > 0 {
+					w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+				}
+				w.Header().Set("Access-Control-Max-Age", "86400")
+			}
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
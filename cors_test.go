@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    string
+	}{
+		{name: "allowed origin", allowed: []string{"https://app.example.com"}, origin: "https://app.example.com", want: "https://app.example.com"},
+		{name: "allowed in another case", allowed: []string{"https://APP.example.com"}, origin: "https://app.example.com", want: "https://app.example.com"},
+		{name: "any origin", allowed: []string{"*"}, origin: "https://else.example.com", want: "https://else.example.com"},
+		{name: "other origin", allowed: []string{"https://app.example.com"}, origin: "https://evil.example.com", want: ""},
+		{name: "disabled", allowed: nil, origin: "https://app.example.com", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.CORS.AllowedOrigins = tt.allowed
+				c.CORS.AllowedHeaders = []string{"Content-Type"}
+			})
+
+			called := false
+			handler := CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+			req := httptest.NewRequest(http.MethodOptions, "/api/posts", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", "GET")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("got status %d, want %d", rec.Code, http.StatusNoContent)
+			}
+			if called {
+				t.Error("the preflight request reached the API")
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+				t.Errorf("got Access-Control-Allow-Origin %q, want %q", got, tt.want)
+			}
+			if tt.want == "" {
+				return
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "GET, OPTIONS" {
+				t.Errorf("got Access-Control-Allow-Methods %q", got)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+				t.Errorf("got Access-Control-Allow-Headers %q", got)
+			}
+		})
+	}
+}
+
+func TestCORSRequest(t *testing.T) {
+	withConfig(t, func(c *Config) { c.CORS.AllowedOrigins = []string{"https://app.example.com"} })
+
+	handler := CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "[]" {
+		t.Errorf("got body %q", rec.Body.String())
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("got Access-Control-Allow-Origin %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "" {
+		t.Errorf("got Access-Control-Allow-Methods %q on a plain request", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("got Vary %q", got)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// CSPAuto is the content_security_policy that's built from what the site uses
+const CSPAuto = "auto"
+
+// disqusSources are what Disqus loads besides its embed script
+var disqusSources = []string{"https://disqus.com", "https://*.disqus.com", "https://*.disquscdn.com"}
+
+// cspPolicy is a Content Security Policy being put together, its directives
+// in the order they were first added
+type cspPolicy struct {
+	names   []string
+	sources map[string][]string
+}
+
+// add allows sources for a directive. Empty sources are skipped, and so is
+// the directive if it ends up with none
+func (p *cspPolicy) add(directive string, sources ...string) {
+	if p.sources == nil {
+		p.sources = map[string][]string{}
+	}
+	for _, source := range sources {
+		if source == "" || containsString(p.sources[directive], source) {
+			continue
+		}
+		if _, ok := p.sources[directive]; !ok {
+			p.names = append(p.names, directive)
+		}
+		p.sources[directive] = append(p.sources[directive], source)
+	}
+}
+
+// String returns the policy as the header value
+func (p *cspPolicy) String() string {
+	directives := make([]string, 0, len(p.names))
+	for _, name := range p.names {
+		directives = append(directives, name+" "+strings.Join(p.sources[name], " "))
+	}
+	return strings.Join(directives, "; ")
+}
+
+// containsString reports whether s is one of list
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// cspSource returns what a CSP needs to allow loading a URL, its scheme and
+// host. URLs on the site itself, or that aren't URLs at all, need nothing
+func cspSource(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	if u.Scheme == "" {
+		return u.Host
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// buildContentSecurityPolicy puts together a CSP allowing the site itself and
+// whatever the enabled features load from elsewhere: Mermaid, comments,
+// analytics and the CDN. Pictures in posts can come from any HTTPS site, and
+// 'unsafe-inline' is only there for browsers too old to know about nonces
+func buildContentSecurityPolicy(c Config) string {
+	var p cspPolicy
+	p.add("default-src", "'self'")
+	p.add("script-src", "'self'", "'unsafe-inline'")
+	p.add("style-src", "'self'", "'unsafe-inline'")
+	p.add("img-src", "'self'", "data:", "https:", cspSource(c.CDNURL))
+	p.add("connect-src", "'self'")
+
+	if c.MermaidURL != "" {
+		p.add("script-src", cspSource(c.MermaidURL))
+	}
+	if c.Comments.ScriptURL != "" {
+		source := cspSource(c.Comments.ScriptURL)
+		p.add("script-src", source)
+		p.add("frame-src", source)
+		if c.Comments.Provider == "disqus" {
+			p.add("script-src", disqusSources...)
+			p.add("frame-src", disqusSources...)
+			p.add("connect-src", disqusSources...)
+		}
+	}
+	if c.Analytics.ScriptURL != "" {
+		// Goatcounter's site ID is the URL it counts to
+		p.add("script-src", cspSource(c.Analytics.ScriptURL))
+		p.add("connect-src", cspSource(c.Analytics.ScriptURL), cspSource(c.Analytics.SiteID))
+		p.add("img-src", cspSource(c.Analytics.SiteID))
+	}
+
+	p.add("object-src", "'none'")
+	p.add("base-uri", "'self'")
+	switch strings.ToUpper(c.SecurityHeaders.FrameOptions) {
+	case "DENY":
+		p.add("frame-ancestors", "'none'")
+	case "SAMEORIGIN":
+		p.add("frame-ancestors", "'self'")
+	}
+	return p.String()
+}
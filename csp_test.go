@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestBuildContentSecurityPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(c *Config)
+		want string
+	}{
+		{
+			name: "defaults",
+			set:  func(c *Config) {},
+			want: "default-src 'self'; script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; connect-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+		},
+		{
+			name: "comments and CDN",
+			set: func(c *Config) {
+				c.MermaidURL = ""
+				c.CDNURL = "https://cdn.example.com/site"
+				c.Comments.ScriptURL = "https://giscus.app/client.js"
+				c.SecurityHeaders.FrameOptions = "SAMEORIGIN"
+			},
+			want: "default-src 'self'; script-src 'self' 'unsafe-inline' https://giscus.app; style-src 'self' 'unsafe-inline'; img-src 'self' data: https: https://cdn.example.com; connect-src 'self'; frame-src https://giscus.app; object-src 'none'; base-uri 'self'; frame-ancestors 'self'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := DefaultConfig()
+			tt.set(&c)
+			if got := buildContentSecurityPolicy(c); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
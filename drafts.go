@@ -0,0 +1,104 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+)
+
+// registerDrafts adds the drafts view, on the admin router if there's one.
+// Outside of dev mode it needs the admin credentials, so without a password
+// there's no view at all
+func registerDrafts(r *mux.Router, admin *mux.Router) {
+	if admin != nil {
+		r = admin
+	}
+
+	var list, draft http.Handler = http.HandlerFunc(DraftsHandler), http.HandlerFunc(DraftHandler)
+	if !devMode {
+		if config.Admin.Password == "" {
+			log.Printf("Warning: drafts in %s can't be seen, the drafts view needs an admin password", config.DraftsDir)
+			return
+		}
+		list, draft = BasicAuth(list), BasicAuth(draft)
+	}
+	r.Handle("/drafts", list).Methods("GET")
+	r.Handle("/drafts/{slug}", draft).Methods("GET")
+}
+
+// draftURL returns the path a draft is served at
+func draftURL(post Post) string {
+	return "/drafts/" + post.Slug()
+}
+
+// getDrafts parses the posts in the drafts directory, which are all drafts
+// whatever their front matter says. Nothing else ever sees them
+func getDrafts() ([]Post, error) {
+	files, err := globPostFiles([]string{config.DraftsDir})
+	if err != nil {
+		return nil, err
+	}
+	if err := uniqueSlugs(files); err != nil {
+		return nil, err
+	}
+
+	drafts, err := loadPosts(files)
+	for i := range drafts {
+		drafts[i].Draft = true
+	}
+	return drafts, err
+}
+
+// DraftsHandler lists the posts in the drafts directory
+func DraftsHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := localizedTemplate(w, r, pageTemplate("drafts"))
+	if err != nil {
+		log.Printf("Error localizing templates: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	drafts, err := getDrafts()
+	if err != nil {
+		log.Printf("Error getting drafts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	posts, err := GetAllPosts()
+	if err != nil {
+		log.Printf("Error getting all posts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := newPageData(r, true, posts)
+	data.Drafts = drafts
+
+	w.Header().Set("Content-Type", contentTypeHTML)
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// DraftHandler renders a post of the drafts directory, like the preview
+func DraftHandler(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	post, err := getPostIn([]string{config.DraftsDir}, slug)
+	if os.IsNotExist(err) {
+		log.Printf("Draft not found: %s", slug)
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error getting draft: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	post.Draft = true
+
+	renderPost(w, r, post)
+}
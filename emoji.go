@@ -0,0 +1,132 @@
+package main
+
+import (
+	"regexp"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// emoji maps the most common shortcodes to their Unicode characters
+var emoji = map[string]string{
+	"+1":                       "👍",
+	"-1":                       "👎",
+	"100":                      "💯",
+	"angry":                    "😠",
+	"balloon":                  "🎈",
+	"beer":                     "🍺",
+	"bell":                     "🔔",
+	"book":                     "📖",
+	"boom":                     "💥",
+	"bug":                      "🐛",
+	"bulb":                     "💡",
+	"cake":                     "🍰",
+	"calendar":                 "📆",
+	"cat":                      "🐱",
+	"chart_with_upwards_trend": "📈",
+	"clap":                     "👏",
+	"coffee":                   "☕",
+	"computer":                 "💻",
+	"confused":                 "😕",
+	"construction":             "🚧",
+	"cry":                      "😢",
+	"dog":                      "🐶",
+	"earth_africa":             "🌍",
+	"email":                    "📧",
+	"exclamation":              "❗",
+	"eyes":                     "👀",
+	"fire":                     "🔥",
+	"gb":                       "🇬🇧",
+	"gear":                     "⚙️",
+	"gift":                     "🎁",
+	"grin":                     "😁",
+	"grinning":                 "😀",
+	"hammer":                   "🔨",
+	"heart":                    "❤️",
+	"heart_eyes":               "😍",
+	"heavy_check_mark":         "✔️",
+	"hourglass":                "⌛",
+	"house":                    "🏠",
+	"hugs":                     "🤗",
+	"it":                       "🇮🇹",
+	"joy":                      "😂",
+	"keyboard":                 "⌨️",
+	"kiss":                     "😘",
+	"laughing":                 "😆",
+	"link":                     "🔗",
+	"lock":                     "🔒",
+	"mag":                      "🔍",
+	"memo":                     "📝",
+	"muscle":                   "💪",
+	"musical_note":             "🎵",
+	"ok_hand":                  "👌",
+	"package":                  "📦",
+	"party_popper":             "🎉",
+	"pensive":                  "😔",
+	"pizza":                    "🍕",
+	"point_right":              "👉",
+	"pray":                     "🙏",
+	"question":                 "❓",
+	"rage":                     "😡",
+	"rainbow":                  "🌈",
+	"raised_hands":             "🙌",
+	"relieved":                 "😌",
+	"rocket":                   "🚀",
+	"rofl":                     "🤣",
+	"scream":                   "😱",
+	"see_no_evil":              "🙈",
+	"shrug":                    "🤷",
+	"skull":                    "💀",
+	"sleeping":                 "😴",
+	"smile":                    "😄",
+	"smiley":                   "😃",
+	"smirk":                    "😏",
+	"snowflake":                "❄️",
+	"sob":                      "😭",
+	"sparkles":                 "✨",
+	"star":                     "⭐",
+	"sun_with_face":            "🌞",
+	"sunglasses":               "😎",
+	"sweat_smile":              "😅",
+	"tada":                     "🎉",
+	"thinking":                 "🤔",
+	"thumbsdown":               "👎",
+	"thumbsup":                 "👍",
+	"tired_face":               "😫",
+	"trophy":                   "🏆",
+	"umbrella":                 "☔",
+	"unamused":                 "😒",
+	"upside_down_face":         "🙃",
+	"warning":                  "⚠️",
+	"wave":                     "👋",
+	"white_check_mark":         "✅",
+	"wine_glass":               "🍷",
+	"wink":                     "😉",
+	"wrench":                   "🔧",
+	"x":                        "❌",
+	"yum":                      "😋",
+	"zap":                      "⚡",
+	"zipper_mouth_face":        "🤐",
+}
+
+var emojiPattern = regexp.MustCompile(`:([a-z0-9_+\-]+):`)
+
+// ReplaceEmoji replaces the known :shortcodes: in text, leaving unknown ones alone
+func ReplaceEmoji(text []byte) []byte {
+	return emojiPattern.ReplaceAllFunc(text, func(match []byte) []byte {
+		if e, ok := emoji[string(match[1:len(match)-1])]; ok {
+			return []byte(e)
+		}
+		return match
+	})
+}
+
+// renderEmoji replaces shortcodes in the text of a parsed document. Code spans
+// and blocks are different nodes, so their content is left as it is
+func renderEmoji(doc ast.Node) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if text, ok := node.(*ast.Text); ok && entering {
+			text.Literal = ReplaceEmoji(text.Literal)
+		}
+		return ast.GoToNext
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplaceEmoji(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "Done :tada:", want: "Done 🎉"},
+		{text: ":+1::-1:", want: "👍👎"},
+		{text: "Not a :shortcode_we_know:", want: "Not a :shortcode_we_know:"},
+		{text: "Times like 10:30:00", want: "Times like 10:30:00"},
+		{text: "No emoji", want: "No emoji"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := string(ReplaceEmoji([]byte(tt.text))); got != tt.want {
+				t.Errorf("ReplaceEmoji(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmojiOutsideCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		emoji   bool
+		want    string
+		notWant string
+	}{
+		{name: "text", source: "Shipped :tada:\n", emoji: true, want: "Shipped 🎉", notWant: ":tada:"},
+		{name: "fence", source: "```\nShipped :tada:\n```\n", emoji: true, want: "Shipped :tada:", notWant: "🎉"},
+		{name: "code span", source: "Type `:tada:`\n", emoji: true, want: "<code>:tada:</code>", notWant: "🎉"},
+		{name: "disabled", source: "Shipped :tada:\n", emoji: false, want: "Shipped :tada:", notWant: "🎉"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.Emoji = tt.emoji })
+
+			post, err := parsePostBytes("emoji.md", []byte("title: Emoji\n---\n"+tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := string(post.Body)
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("body has no %q: %s", tt.want, body)
+			}
+			if strings.Contains(body, tt.notWant) {
+				t.Errorf("body has %q: %s", tt.notWant, body)
+			}
+		})
+	}
+}
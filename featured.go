@@ -0,0 +1,46 @@
+package main
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ImageURL returns the URL of the featured image of the post. Relative paths
+// are taken as the post's own assets, in /static/img/<slug>/
+func (p Post) ImageURL() string {
+	if p.Image == "" || strings.HasPrefix(p.Image, "/") || strings.Contains(p.Image, "://") {
+		return p.Image
+	}
+	return path.Join("/static/img", p.Slug(), p.Image)
+}
+
+// imageSize returns the dimensions of an image served from static/, or zeros
+// if it can't be read, as is the case for remote images
+func imageSize(imageURL string) (int, int) {
+	if !strings.HasPrefix(imageURL, "/static/") {
+		return 0, 0
+	}
+	name, err := url.PathUnescape(strings.TrimPrefix(imageURL, "/static/"))
+	if err != nil || !filepath.IsLocal(name) {
+		return 0, 0
+	}
+
+	f, err := os.Open(filepath.Join("static", name))
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+)
+
+// RSS represents the RSS feed
+type RSS struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	DC      string   `xml:"xmlns:dc,attr"`
+	Channel Channel  `xml:"channel"`
+
+	// Hash is the PostsHash of the posts in the feed, see feedETag
+	Hash string `xml:"-"`
+}
+
+// Channel represents the RSS channel
+type Channel struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Language    string `xml:"language"`
+	Copyright   string `xml:"copyright,omitempty"`
+	Items       []Item `xml:"item"`
+}
+
+// Item represents an item in the RSS feed
+type Item struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	GUID        string   `xml:"guid"`
+	Categories  []string `xml:"category"`
+	Rights      string   `xml:"dc:rights,omitempty"`
+}
+
+// Feed modes, what the description of the feed items has
+const (
+	FeedParagraphs = "paragraphs"
+	FeedFull       = "full"
+	FeedSummary    = "summary"
+	FeedExcerpt    = "excerpt"
+)
+
+// feedDescription returns the description of a post in the feed, depending on
+// the feed mode. link is the absolute URL of the post
+func feedDescription(post Post, link string) string {
+	switch config.FeedMode {
+	case FeedFull:
+		return string(post.Body)
+	case FeedSummary:
+		return html.EscapeString(post.Description())
+	case FeedExcerpt:
+		return fmt.Sprintf(`<p>%s</p><p><a href="%s">%s</a></p>`,
+			html.EscapeString(post.Description()), html.EscapeString(link), html.EscapeString(T("Read the full post →")))
+	}
+
+	return firstParagraphs(string(post.Body), 2)
+}
+
+// firstParagraphs returns body up to the end of its nth paragraph, or all of
+// it if it has fewer, without trailing whitespace
+func firstParagraphs(body string, n int) string {
+	end := 0
+	for i := 0; i < n; i++ {
+		next := strings.Index(body[end:], "</p>")
+		if next < 0 {
+			end = len(body)
+			break
+		}
+		end += next + len("</p>")
+	}
+	return strings.TrimRightFunc(body[:end], unicode.IsSpace)
+}
+
+// BuildFeed creates an RSS feed out of the published posts. base is the
+// scheme and host the links start with
+func BuildFeed(posts []Post, title string, base string) RSS {
+	var rssItems []Item
+	for _, post := range posts {
+		link := base + postURL(post)
+		item := Item{
+			Title:       post.Title,
+			Link:        link,
+			Description: feedDescription(post, link),
+			PubDate:     FormatDate(time.RFC1123, post.Date),
+			GUID:        post.Filename,
+			Categories:  post.TagList(),
+		}
+		if license := post.LicenseInfo(); license != nil {
+			item.Rights = license.Rights()
+		}
+		rssItems = append(rssItems, item)
+	}
+
+	copyright := ""
+	if license := lookupLicense(config.License); license != nil {
+		copyright = license.Rights()
+	}
+
+	return RSS{
+		Hash:    PostsHash(posts),
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: Channel{
+			Title:       title,
+			Link:        config.BaseURL,
+			Description: config.Description,
+			Language:    config.Language,
+			Copyright:   copyright,
+			Items:       rssItems,
+		},
+	}
+}
+
+// feedETag is the ETag of a feed served with the given base URL. Besides the
+// posts, it changes with everything else the feed is made of: the host, the
+// channel, its language and the settings the items are rendered with
+func feedETag(feed RSS, base string) string {
+	return contentHash([]byte(strings.Join([]string{
+		feed.Hash, base,
+		feed.Channel.Title, feed.Channel.Link, feed.Channel.Description, feed.Channel.Language, feed.Channel.Copyright,
+		config.Language, config.FeedMode, strconv.Itoa(config.DescriptionLength), config.Timezone, config.CDNURL, trailingSlashPolicy,
+	}, "\n")))
+}
+
+// writeFeed encodes the feed as the response, unless the client has it
+// already
+func writeFeed(w http.ResponseWriter, r *http.Request, feed RSS) {
+	if notModified(w, r, feedETag(feed, baseURL(r))) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", "inline")
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		log.Printf("Error encoding RSS feed: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// RSSHandler generates the RSS feed
+func RSSHandler(w http.ResponseWriter, r *http.Request) {
+	posts, err := GetAllPosts()
+	if err != nil {
+		log.Printf("Error getting all posts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Filter out drafts and expired posts
+	listed := ListedPosts(posts, time.Now())
+
+	// ?lang= gives a feed with only the posts in that language
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		writeFeed(w, r, BuildFeed(listed, config.Title, baseURL(r)))
+		return
+	}
+
+	feed := BuildFeed(PostsInLanguage(listed, lang), config.Title, baseURL(r))
+	feed.Channel.Language = lang
+	writeFeed(w, r, feed)
+}
+
+// TagFeedHandler generates the RSS feed of the posts with a given tag
+func TagFeedHandler(w http.ResponseWriter, r *http.Request) {
+	tag := mux.Vars(r)["tag"]
+
+	posts, err := GetAllPosts()
+	if err != nil {
+		log.Printf("Error getting all posts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tagged := taggedPosts(ListedPosts(posts, time.Now()), tag)
+	if len(tagged) == 0 {
+		log.Printf("No posts tagged: %s", tag)
+		http.NotFound(w, r)
+		return
+	}
+
+	writeFeed(w, r, BuildFeed(tagged, fmt.Sprintf("%s #%s", config.Title, tag), baseURL(r)))
+}
+
+// OPML is a list of feeds to subscribe to at once
+type OPML struct {
+	XMLName xml.Name  `xml:"opml"`
+	Version string    `xml:"version,attr"`
+	Title   string    `xml:"head>title"`
+	Feeds   []Outline `xml:"body>outline"`
+}
+
+// Outline is a feed in an OPML list
+type Outline struct {
+	Type    string `xml:"type,attr"`
+	Text    string `xml:"text,attr"`
+	Title   string `xml:"title,attr"`
+	XMLURL  string `xml:"xmlUrl,attr"`
+	HTMLURL string `xml:"htmlUrl,attr"`
+}
+
+// OPMLHandler lists the main feed and the feed of every tag as OPML
+func OPMLHandler(w http.ResponseWriter, r *http.Request) {
+	posts, err := GetAllPosts()
+	if err != nil {
+		log.Printf("Error getting all posts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tags := allTags(ListedPosts(posts, time.Now()))
+	base := strings.TrimSuffix(config.BaseURL, "/")
+	opml := OPML{
+		Version: "2.0",
+		Title:   config.Title,
+		Feeds: []Outline{{
+			Type:    "rss",
+			Text:    config.Title,
+			Title:   config.Title,
+			XMLURL:  base + "/feed.xml",
+			HTMLURL: base + "/",
+		}},
+	}
+	for _, tag := range tags {
+		title := fmt.Sprintf("%s #%s", config.Title, tag)
+		opml.Feeds = append(opml.Feeds, Outline{
+			Type:    "rss",
+			Text:    title,
+			Title:   title,
+			XMLURL:  fmt.Sprintf("%s/tag/%s/feed.xml", base, url.PathEscape(tag)),
+			HTMLURL: base + "/",
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(opml); err != nil {
+		log.Printf("Error encoding OPML: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
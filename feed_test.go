@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// categoryPattern matches the category elements of a feed
+var categoryPattern = regexp.MustCompile(`<category>([^<]*)</category>`)
+
+// getFeed serves the feed at path and decodes it
+func getFeed(t *testing.T, handler http.HandlerFunc, path string, vars map[string]string) RSS {
+	t.Helper()
+
+	rec := serve(handler, path, vars)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d", rec.Code)
+	}
+	var feed RSS
+	if err := xml.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	return feed
+}
+
+func TestFeedLanguage(t *testing.T) {
+	withPosts(t, map[string]string{
+		"hello.md": "title: Hello\ndate: 2024-01-01T00:00:00Z\n---\nHello\n",
+		"ciao.md":  "title: Ciao\ndate: 2024-01-02T00:00:00Z\nlang: it\n---\nCiao\n",
+	}, nil)
+
+	tests := []struct {
+		path     string
+		language string
+		titles   []string
+	}{
+		{path: "/feed.xml", language: "en-gb", titles: []string{"Ciao", "Hello"}},
+		{path: "/feed.xml?lang=it", language: "it", titles: []string{"Ciao"}},
+		{path: "/feed.xml?lang=en-GB", language: "en-GB", titles: []string{"Hello"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			feed := getFeed(t, RSSHandler, tt.path, nil)
+			if feed.Channel.Language != tt.language {
+				t.Errorf("got language %q, want %q", feed.Channel.Language, tt.language)
+			}
+			var titles []string
+			for _, item := range feed.Channel.Items {
+				titles = append(titles, item.Title)
+			}
+			if !reflect.DeepEqual(titles, tt.titles) {
+				t.Errorf("got items %v, want %v", titles, tt.titles)
+			}
+		})
+	}
+}
+
+func TestFeedCategories(t *testing.T) {
+	withConfig(t, nil)
+
+	tests := []struct {
+		name string
+		tags string
+		want []string
+	}{
+		{name: "several tags", tags: "go, web,testing", want: []string{"go", "web", "testing"}},
+		{name: "empty tags", tags: "go, , web,", want: []string{"go", "web"}},
+		{name: "no tags", tags: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post := Post{Filename: "post.md", Title: "Post", Date: "2024-01-01T00:00:00Z", Tags: tt.tags, Type: TypePost}
+			out, err := xml.Marshal(BuildFeed([]Post{post}, "Feed", "http://example.com"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, m := range categoryPattern.FindAllStringSubmatch(string(out), -1) {
+				got = append(got, m[1])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got categories %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOPML(t *testing.T) {
+	tests := []struct {
+		name  string
+		posts map[string]string
+		want  []string
+	}{
+		{
+			name: "a feed per tag",
+			posts: map[string]string{
+				"one.md": "title: One\ndate: 2024-01-01T00:00:00Z\ntags: go, web\n---\nOne\n",
+				"two.md": "title: Two\ndate: 2024-01-02T00:00:00Z\ntags: go, c++\n---\nTwo\n",
+			},
+			want: []string{
+				"http://example.com/feed.xml",
+				"http://example.com/tag/c++/feed.xml",
+				"http://example.com/tag/go/feed.xml",
+				"http://example.com/tag/web/feed.xml",
+			},
+		},
+		{
+			name: "tags of drafts are left out",
+			posts: map[string]string{
+				"one.md":   "title: One\ndate: 2024-01-01T00:00:00Z\ntags: go\n---\nOne\n",
+				"draft.md": "title: Draft\ndate: 2024-01-02T00:00:00Z\ntags: secret\ndraft: true\n---\nDraft\n",
+			},
+			want: []string{
+				"http://example.com/feed.xml",
+				"http://example.com/tag/go/feed.xml",
+			},
+		},
+		{
+			name:  "no tags",
+			posts: map[string]string{"one.md": "title: One\ndate: 2024-01-01T00:00:00Z\n---\nOne\n"},
+			want:  []string{"http://example.com/feed.xml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, tt.posts, func(c *Config) {
+				c.Title = "Blog"
+				c.BaseURL = "http://example.com/"
+			})
+
+			rec := serve(OPMLHandler, "/feeds.opml", nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d", rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "text/x-opml; charset=utf-8" {
+				t.Errorf("got content type %q", ct)
+			}
+
+			var opml OPML
+			if err := xml.Unmarshal(rec.Body.Bytes(), &opml); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, feed := range opml.Feeds {
+				got = append(got, feed.XMLURL)
+				if feed.Type != "rss" || feed.HTMLURL != "http://example.com/" {
+					t.Errorf("outline %+v", feed)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got feeds %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstParagraphs(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		n    int
+		want string
+	}{
+		{name: "more paragraphs", body: "<p>One</p>\n<p>Two</p>\n<p>Three</p>\n", n: 2, want: "<p>One</p>\n<p>Two</p>"},
+		{name: "exactly n paragraphs", body: "<p>One</p>\n<p>Two</p>\n", n: 2, want: "<p>One</p>\n<p>Two</p>"},
+		{name: "fewer paragraphs", body: "<p>One</p>\n", n: 2, want: "<p>One</p>"},
+		{name: "trailing whitespace", body: "<p>One</p>\n\n  \n", n: 1, want: "<p>One</p>"},
+		{name: "something else after", body: "<p>One</p>\n<ul>\n<li>Two</li>\n</ul>\n", n: 1, want: "<p>One</p>"},
+		{name: "something else first", body: "<h2>Title</h2>\n<p>One</p>\n<p>Two</p>\n", n: 1, want: "<h2>Title</h2>\n<p>One</p>"},
+		{name: "no paragraphs", body: "<ul>\n<li>One</li>\n</ul>\n", n: 2, want: "<ul>\n<li>One</li>\n</ul>"},
+		{name: "empty", body: "", n: 2, want: ""},
+		{name: "none asked for", body: "<p>One</p>\n", n: 0, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := firstParagraphs(tt.body, tt.n)
+			if got != tt.want {
+				t.Errorf("firstParagraphs() = %q, want %q", got, tt.want)
+			}
+			if opened, closed := strings.Count(got, "<p>"), strings.Count(got, "</p>"); opened != closed {
+				t.Errorf("%d <p> and %d </p> in %q", opened, closed, got)
+			}
+		})
+	}
+}
+
+func TestFeedETag(t *testing.T) {
+	posts := map[string]string{
+		"hello.md": "title: Hello\ndate: 2024-01-01T00:00:00Z\n---\nHello\n",
+		"ciao.md":  "title: Ciao\ndate: 2024-01-02T00:00:00Z\nlang: it\n---\nCiao\n",
+	}
+
+	// etag serves the feed for a request, with config changed by set
+	etag := func(t *testing.T, target string, set func(c *Config)) string {
+		t.Helper()
+		withPosts(t, posts, set)
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		RSSHandler(rec, r)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d", rec.Code)
+		}
+		return rec.Header().Get("ETag")
+	}
+
+	base := etag(t, "http://example.com/feed.xml", nil)
+	if base == "" {
+		t.Fatal("the feed has no ETag")
+	}
+	if again := etag(t, "http://example.com/feed.xml", nil); again != base {
+		t.Errorf("the ETag of the same feed changed from %s to %s", base, again)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		set    func(c *Config)
+	}{
+		{name: "other host", target: "http://example.org/feed.xml"},
+		{name: "other scheme", target: "https://example.com/feed.xml"},
+		{name: "feed mode", target: "http://example.com/feed.xml", set: func(c *Config) { c.FeedMode = FeedFull }},
+		{name: "site language", target: "http://example.com/feed.xml", set: func(c *Config) { c.Language = "it" }},
+		{name: "title", target: "http://example.com/feed.xml", set: func(c *Config) { c.Title = "Other" }},
+		{name: "language", target: "http://example.com/feed.xml?lang=en-gb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etag(t, tt.target, tt.set); got == base {
+				t.Errorf("same ETag %s for a different feed", got)
+			}
+		})
+	}
+
+	// A client with the ETag of one host's feed gets the other host's in full
+	withPosts(t, posts, nil)
+	r := httptest.NewRequest(http.MethodGet, "http://example.org/feed.xml", nil)
+	r.Header.Set("If-None-Match", base)
+	rec := httptest.NewRecorder()
+	RSSHandler(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Errorf("got status %d for another host's ETag", rec.Code)
+	}
+	r = httptest.NewRequest(http.MethodGet, "http://example.com/feed.xml", nil)
+	r.Header.Set("If-None-Match", base)
+	rec = httptest.NewRecorder()
+	RSSHandler(rec, r)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("got status %d for the same feed", rec.Code)
+	}
+}
@@ -5,5 +5,12 @@ go 1.20
 require (
 	github.com/gomarkdown/markdown v0.0.0-20240626202925-2eda941fd024
 	github.com/gorilla/mux v1.8.1
+	golang.org/x/crypto v0.24.0
+	golang.org/x/image v0.18.0
 	gopkg.in/yaml.v2 v2.4.0
 )
+
+require (
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+)
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// contentHash returns the hash of the raw content of a post file. It's what
+// tells whether a post changed, for everything that caches something made out
+// of one
+func contentHash(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+// PostsHash returns a hash of a list of posts, which changes when any of them
+// does or the list itself does
+func PostsHash(posts []Post) string {
+	h := sha256.New()
+	for _, post := range posts {
+		io.WriteString(h, post.Filename+"\x00"+post.ContentHash+"\n")
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// notModified sets the ETag of a response made from content with the given
+// hash, and answers 304 if the client already has it
+func notModified(w http.ResponseWriter, r *http.Request, hash string) bool {
+	etag := `"` + hash + `"`
+	w.Header().Set("ETag", etag)
+	for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if match = strings.TrimSpace(match); match == etag || match == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContentHash(t *testing.T) {
+	withConfig(t, nil)
+	original := "title: Hello\ndate: 2024-01-01T00:00:00Z\n---\nHello, world.\n"
+
+	tests := []struct {
+		name    string
+		content string
+		changes bool
+	}{
+		{name: "same content", content: original, changes: false},
+		{name: "body", content: "title: Hello\ndate: 2024-01-01T00:00:00Z\n---\nHello, world!\n", changes: true},
+		{name: "front matter", content: "title: Hi\ndate: 2024-01-01T00:00:00Z\n---\nHello, world.\n", changes: true},
+		{name: "trailing newline", content: original + "\n", changes: true},
+		{name: "whitespace only", content: "title: Hello\ndate: 2024-01-01T00:00:00Z\n---\nHello,  world.\n", changes: true},
+	}
+
+	before, err := parsePostBytes("hello.md", []byte(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.ContentHash == "" {
+		t.Fatal("the post has no hash")
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after, err := parsePostBytes("hello.md", []byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := after.ContentHash != before.ContentHash; changed != tt.changes {
+				t.Errorf("hash changed: %v, want %v", changed, tt.changes)
+			}
+		})
+	}
+}
+
+func TestPostsHash(t *testing.T) {
+	a := Post{Filename: "a.md", ContentHash: contentHash([]byte("a"))}
+	b := Post{Filename: "b.md", ContentHash: contentHash([]byte("b"))}
+	edited := Post{Filename: "b.md", ContentHash: contentHash([]byte("b, edited"))}
+	renamed := Post{Filename: "c.md", ContentHash: b.ContentHash}
+
+	hash := PostsHash([]Post{a, b})
+	if PostsHash([]Post{a, b}) != hash {
+		t.Error("the hash of the same posts changed")
+	}
+
+	tests := []struct {
+		name  string
+		posts []Post
+	}{
+		{name: "edited post", posts: []Post{a, edited}},
+		{name: "renamed post", posts: []Post{a, renamed}},
+		{name: "new post", posts: []Post{a, b, renamed}},
+		{name: "removed post", posts: []Post{a}},
+		{name: "other order", posts: []Post{b, a}},
+		{name: "no posts", posts: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if PostsHash(tt.posts) == hash {
+				t.Error("the hash didn't change")
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`"def"`, false},
+		{`"def", "abc"`, true},
+		{"*", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ifNoneMatch, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/feed.xml", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+
+			if got := notModified(rec, r, "abc"); got != tt.want {
+				t.Errorf("notModified() = %v, want %v", got, tt.want)
+			}
+			if etag := rec.Header().Get("ETag"); etag != `"abc"` {
+				t.Errorf("got ETag %s", etag)
+			}
+			if tt.want && rec.Code != http.StatusNotModified {
+				t.Errorf("got status %d", rec.Code)
+			}
+		})
+	}
+}
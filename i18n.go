@@ -0,0 +1,54 @@
+package main
+
+import "strings"
+
+// Alternate is a version of a page in another language, for hreflang links
+type Alternate struct {
+	Lang string
+	URL  string
+}
+
+// PostsInLanguage returns the posts written in lang, ignoring case
+func PostsInLanguage(posts []Post, lang string) []Post {
+	var filtered []Post
+	for _, post := range posts {
+		if strings.EqualFold(post.Lang, lang) {
+			filtered = append(filtered, post)
+		}
+	}
+	return filtered
+}
+
+// Translations returns the posts sharing the translation key of post, other
+// than post itself
+func Translations(post Post, posts []Post) []Post {
+	var translations []Post
+	if post.TranslationKey == "" {
+		return translations
+	}
+	for _, p := range posts {
+		if p.TranslationKey == post.TranslationKey && p.Filename != post.Filename {
+			translations = append(translations, p)
+		}
+	}
+	return translations
+}
+
+// alternates returns the hreflang links for a post and its translations. The
+// post itself is included, as search engines expect the set to be complete.
+// base is the scheme and host the links start with
+func alternates(base string, post Post, translations []Post) []Alternate {
+	if len(translations) == 0 {
+		return nil
+	}
+
+	link := func(p Post) Alternate {
+		return Alternate{Lang: p.Lang, URL: base + postURL(p)}
+	}
+
+	alts := []Alternate{link(post)}
+	for _, t := range translations {
+		alts = append(alts, link(t))
+	}
+	return alts
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// siteIcons maps the icons browsers ask for at the root to their content type
+var siteIcons = map[string]string{
+	"favicon.ico":          "image/x-icon",
+	"apple-touch-icon.png": "image/png",
+}
+
+// Manifest is a minimal web app manifest
+type Manifest struct {
+	Name            string         `json:"name"`
+	ShortName       string         `json:"short_name"`
+	Description     string         `json:"description,omitempty"`
+	StartURL        string         `json:"start_url"`
+	Display         string         `json:"display"`
+	ThemeColor      string         `json:"theme_color,omitempty"`
+	BackgroundColor string         `json:"background_color,omitempty"`
+	Icons           []ManifestIcon `json:"icons,omitempty"`
+}
+
+// ManifestIcon is an icon listed in the manifest
+type ManifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type"`
+}
+
+// iconFile returns where a site icon lives. The favicon can be configured
+// explicitly, everything else comes from the icons directory
+func iconFile(name string) string {
+	if name == "favicon.ico" && config.Favicon != "" {
+		return config.Favicon
+	}
+	return filepath.Join(config.IconsDir, name)
+}
+
+// IconHandler serves one of the site icons
+func IconHandler(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		file := iconFile(name)
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			log.Printf("Error reading %s: %v", file, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// A configured favicon might well be a PNG or an SVG
+		contentType := siteIcons[name]
+		if t := mime.TypeByExtension(filepath.Ext(file)); t != "" && filepath.Ext(file) != ".ico" {
+			contentType = t
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "public, max-age=604800")
+		http.ServeFile(w, r, file)
+	}
+}
+
+// ManifestHandler serves site.webmanifest from the icons directory if there
+// is one, or generates it from the site configuration otherwise
+func ManifestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/manifest+json")
+	w.Header().Set("Cache-Control", "public, max-age=604800")
+
+	file := filepath.Join(config.IconsDir, "site.webmanifest")
+	if _, err := os.Stat(file); err == nil {
+		http.ServeFile(w, r, file)
+		return
+	}
+
+	manifest := Manifest{
+		Name:            config.Title,
+		ShortName:       config.Title,
+		Description:     config.Description,
+		StartURL:        "/",
+		Display:         "browser",
+		ThemeColor:      config.ThemeColor,
+		BackgroundColor: config.Background,
+	}
+	if _, err := os.Stat(iconFile("apple-touch-icon.png")); err == nil {
+		manifest.Icons = append(manifest.Icons, ManifestIcon{
+			Src:   "/apple-touch-icon.png",
+			Sizes: "180x180",
+			Type:  "image/png",
+		})
+	}
+
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		log.Printf("Error encoding manifest: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManifestHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		icons map[string]string
+		want  Manifest
+	}{
+		{
+			name:  "no icons",
+			icons: map[string]string{},
+			want: Manifest{
+				Name:            "Notebook",
+				ShortName:       "Notebook",
+				StartURL:        "/",
+				Display:         "browser",
+				ThemeColor:      "#123456",
+				BackgroundColor: "#F0E1CE",
+			},
+		},
+		{
+			name:  "touch icon",
+			icons: map[string]string{"apple-touch-icon.png": "png"},
+			want: Manifest{
+				Name:            "Notebook",
+				ShortName:       "Notebook",
+				StartURL:        "/",
+				Display:         "browser",
+				ThemeColor:      "#123456",
+				BackgroundColor: "#F0E1CE",
+				Icons:           []ManifestIcon{{Src: "/apple-touch-icon.png", Sizes: "180x180", Type: "image/png"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.icons)
+			withConfig(t, func(c *Config) {
+				c.Title = "Notebook"
+				c.Description = ""
+				c.ThemeColor = "#123456"
+				c.IconsDir = dir
+			})
+
+			rec := httptest.NewRecorder()
+			ManifestHandler(rec, httptest.NewRequest(http.MethodGet, "/site.webmanifest", nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/manifest+json" {
+				t.Errorf("got content type %q", got)
+			}
+			var got Manifest
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIconHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		icon        string
+		favicon     string
+		files       map[string]string
+		status      int
+		contentType string
+	}{
+		{name: "favicon", icon: "favicon.ico", files: map[string]string{"favicon.ico": "ico"}, status: http.StatusOK, contentType: "image/x-icon"},
+		{name: "configured svg favicon", icon: "favicon.ico", favicon: "logo.svg", files: map[string]string{"logo.svg": "<svg/>"}, status: http.StatusOK, contentType: "image/svg+xml"},
+		{name: "missing", icon: "apple-touch-icon.png", files: map[string]string{}, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			withConfig(t, func(c *Config) {
+				c.IconsDir = dir
+				if tt.favicon != "" {
+					c.Favicon = filepath.Join(dir, tt.favicon)
+				}
+			})
+
+			rec := httptest.NewRecorder()
+			IconHandler(tt.icon)(rec, httptest.NewRequest(http.MethodGet, "/"+tt.icon, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Content-Type"); tt.contentType != "" && got != tt.contentType {
+				t.Errorf("got content type %q, want %q", got, tt.contentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// includesDir is where the snippets pulled in by the include directive live
+var includesDir = "includes"
+
+// maxIncludeDepth is how deep includes can be nested, which also stops a
+// snippet from including itself forever
+const maxIncludeDepth = 5
+
+// includeDirective matches {{< include "snippet.md" >}}
+var includeDirective = regexp.MustCompile(`\{\{<\s*include\s+"([^"]+)"\s*>\}\}`)
+
+// expandIncludes replaces the include directives in a post's Markdown with the
+// content of the snippets, recursively, and returns the files it read. Code
+// blocks are left alone
+func expandIncludes(source string, depth int) (string, []string, error) {
+	var out strings.Builder
+	var files []string
+	inCode := false
+	for _, line := range strings.SplitAfter(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+		}
+		if inCode || !includeDirective.MatchString(line) {
+			out.WriteString(line)
+			continue
+		}
+
+		var err error
+		line = includeDirective.ReplaceAllStringFunc(line, func(directive string) string {
+			if err != nil {
+				return ""
+			}
+			name := includeDirective.FindStringSubmatch(directive)[1]
+			var snippet string
+			var read []string
+			snippet, read, err = readInclude(name, depth)
+			files = append(files, read...)
+			return snippet
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		out.WriteString(line)
+	}
+	return out.String(), files, nil
+}
+
+// readInclude reads a snippet from the includes directory and expands its
+// own includes, returning the files read. Names can't point outside the
+// directory
+func readInclude(name string, depth int) (string, []string, error) {
+	if !filepath.IsLocal(name) {
+		return "", nil, fmt.Errorf("invalid include %q", name)
+	}
+	if depth >= maxIncludeDepth {
+		return "", nil, fmt.Errorf("include %q nested deeper than %d", name, maxIncludeDepth)
+	}
+
+	file := filepath.Join(includesDir, name)
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", nil, fmt.Errorf("include %q: %w", name, err)
+	}
+
+	snippet, files, err := expandIncludes(string(content), depth+1)
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSuffix(snippet, "\n"), append([]string{file}, files...), nil
+}
+
+// includeFiles lists the files in the includes directory, none if there's no
+// such directory
+func includeFiles() ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(includesDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == includesDir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withIncludes runs a test with the given snippets in the includes directory
+func withIncludes(t *testing.T, snippets map[string]string) {
+	t.Helper()
+
+	saved := includesDir
+	t.Cleanup(func() { includesDir = saved })
+	includesDir = writeFiles(t, snippets)
+}
+
+func TestIncludeSnippet(t *testing.T) {
+	withConfig(t, nil)
+	withIncludes(t, map[string]string{
+		"bio.md":          "I *write* things.\n",
+		"nested/outer.md": "Outer, {{< include \"nested/inner.md\" >}}\n",
+		"nested/inner.md": "inner.\n",
+	})
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{name: "snippet", source: "{{< include \"bio.md\" >}}\n", want: "<p>I <em>write</em> things.</p>"},
+		{name: "inline", source: "About me: {{< include \"bio.md\" >}}\n", want: "<p>About me: I <em>write</em> things.</p>"},
+		{name: "nested", source: "{{<include \"nested/outer.md\">}}\n", want: "<p>Outer, inner.</p>"},
+		{name: "in code", source: "```\n{{< include \"bio.md\" >}}\n```\n", want: "{{&lt; include &quot;bio.md&quot; &gt;}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if body := renderBody(t, tt.source); !strings.Contains(body, tt.want) {
+				t.Errorf("body has no %q: %s", tt.want, body)
+			}
+		})
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	withIncludes(t, map[string]string{
+		"loop.md": "Again {{< include \"loop.md\" >}}\n",
+	})
+
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{name: "recursion", source: "{{< include \"loop.md\" >}}\n"},
+		{name: "outside the directory", source: "{{< include \"../secret.md\" >}}\n"},
+		{name: "absolute path", source: "{{< include \"/etc/passwd\" >}}\n"},
+		{name: "missing", source: "{{< include \"missing.md\" >}}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _, err := expandIncludes(tt.source, 0); err == nil {
+				t.Errorf("no error, got %q", got)
+			}
+		})
+	}
+}
+
+func TestIncludeEdits(t *testing.T) {
+	withIncludes(t, map[string]string{
+		"bio.md":   "Version one, {{< include \"name.md\" >}}\n",
+		"name.md":  "by Frank.\n",
+		"other.md": "Unused.\n",
+	})
+	withPosts(t, map[string]string{
+		"post.md": "title: Post\ndate: 2024-01-01T00:00:00Z\n---\n{{< include \"bio.md\" >}}\n",
+	}, nil)
+
+	later := time.Now().Add(time.Hour)
+	edit := func(name, content string) {
+		t.Helper()
+		file := filepath.Join(includesDir, name)
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Make sure the modification time moves, however coarse the clock
+		later = later.Add(time.Minute)
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		edit func()
+		want string
+	}{
+		{name: "first render", edit: func() {}, want: "<p>Version one, by Frank.</p>"},
+		{name: "snippet", edit: func() { edit("bio.md", "Version two, {{< include \"name.md\" >}}\n") }, want: "<p>Version two, by Frank.</p>"},
+		{name: "nested snippet", edit: func() { edit("name.md", "by Anna.\n") }, want: "<p>Version two, by Anna.</p>"},
+		{name: "same length", edit: func() { edit("name.md", "by Lena.\n") }, want: "<p>Version two, by Lena.</p>"},
+		{name: "snippet not included", edit: func() { edit("other.md", "Still unused.\n") }, want: "<p>Version two, by Lena.</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.edit()
+
+			post, err := GetPost("post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body := string(post.Body); !strings.Contains(body, tt.want) {
+				t.Errorf("GetPost() body has no %q: %s", tt.want, body)
+			}
+			if body := loadedBody(t, "post.md"); !strings.Contains(body, tt.want) {
+				t.Errorf("GetAllPosts() body has no %q: %s", tt.want, body)
+			}
+		})
+	}
+}
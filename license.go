@@ -0,0 +1,52 @@
+package main
+
+// License is a license posts can be published under
+type License struct {
+	ID   string
+	Name string
+	URL  string
+}
+
+// licenseNone opts a post out of the site license
+const licenseNone = "none"
+
+// licenses are the licenses known by their SPDX id. Others are shown by id,
+// without a link
+var licenses = map[string]License{
+	"CC-BY-4.0":       {"CC-BY-4.0", "CC BY 4.0", "https://creativecommons.org/licenses/by/4.0/"},
+	"CC-BY-SA-4.0":    {"CC-BY-SA-4.0", "CC BY-SA 4.0", "https://creativecommons.org/licenses/by-sa/4.0/"},
+	"CC-BY-NC-4.0":    {"CC-BY-NC-4.0", "CC BY-NC 4.0", "https://creativecommons.org/licenses/by-nc/4.0/"},
+	"CC-BY-NC-SA-4.0": {"CC-BY-NC-SA-4.0", "CC BY-NC-SA 4.0", "https://creativecommons.org/licenses/by-nc-sa/4.0/"},
+	"CC-BY-ND-4.0":    {"CC-BY-ND-4.0", "CC BY-ND 4.0", "https://creativecommons.org/licenses/by-nd/4.0/"},
+	"CC-BY-NC-ND-4.0": {"CC-BY-NC-ND-4.0", "CC BY-NC-ND 4.0", "https://creativecommons.org/licenses/by-nc-nd/4.0/"},
+	"CC0-1.0":         {"CC0-1.0", "CC0 1.0", "https://creativecommons.org/publicdomain/zero/1.0/"},
+	"MIT":             {"MIT", "MIT License", "https://opensource.org/licenses/MIT"},
+}
+
+// lookupLicense returns the license with the given id, nil for none
+func lookupLicense(id string) *License {
+	if id == "" || id == licenseNone {
+		return nil
+	}
+	if l, ok := licenses[id]; ok {
+		return &l
+	}
+	return &License{ID: id, Name: id}
+}
+
+// LicenseInfo returns the license of the post, the site's unless the post has
+// its own, or nil if there's none
+func (p Post) LicenseInfo() *License {
+	if p.License != "" {
+		return lookupLicense(p.License)
+	}
+	return lookupLicense(config.License)
+}
+
+// Rights returns a line describing the license, for feeds
+func (l *License) Rights() string {
+	if l.URL == "" {
+		return "Licensed under " + l.Name
+	}
+	return "Licensed under " + l.Name + " (" + l.URL + ")"
+}
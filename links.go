@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// hrefPattern matches the targets of the links in rendered HTML
+var hrefPattern = regexp.MustCompile(`href="([^"]*)"`)
+
+// linkCheckTimeout is how long an external link gets to answer
+const linkCheckTimeout = 10 * time.Second
+
+// siteLinks knows what internal links can point at
+type siteLinks struct {
+	urls  map[string]bool
+	posts map[string]bool
+	pages map[string]bool
+	tags  map[string]bool
+}
+
+// newSiteLinks indexes the slugs and tags of posts
+func newSiteLinks(posts []Post) siteLinks {
+	s := siteLinks{urls: map[string]bool{}, posts: map[string]bool{}, pages: map[string]bool{}, tags: map[string]bool{}}
+	for _, post := range posts {
+		s.urls[strings.TrimSuffix(postURL(post), "/")] = true
+		s.posts[post.Slug()] = true
+		if post.Type == TypePage {
+			s.pages[post.Slug()] = true
+		}
+		for _, tag := range post.TagList() {
+			s.tags[tag] = true
+		}
+	}
+	return s
+}
+
+// resolves reports whether an absolute path on the site leads somewhere
+func (s siteLinks) resolves(path string) bool {
+	if path == "/" || s.urls[strings.TrimSuffix(path, "/")] || s.urls[strings.TrimSuffix(path, "/og.png")] {
+		return true
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case parts[0] == "static":
+		_, err := os.Stat(filepath.Join("static", strings.TrimPrefix(path, "/static/")))
+		return err == nil
+	case parts[0] == "post" && len(parts) >= 2:
+		if !s.posts[trimPostExtension(parts[1])] {
+			return false
+		}
+		return len(parts) == 2 || (len(parts) == 3 && (parts[2] == "og.png" || parts[2] == "meta"))
+	case parts[0] == "tag" && (len(parts) == 2 || len(parts) == 3):
+		return s.tags[parts[1]] && (len(parts) == 2 || parts[2] == "feed.xml")
+	case len(parts) == 1:
+		return reservedSlugs[parts[0]] || s.pages[parts[0]]
+	}
+	return false
+}
+
+// isInternalLink reports whether a link points at the site itself
+func isInternalLink(u *url.URL) bool {
+	if u.Host == "" {
+		return true
+	}
+	base, err := url.Parse(config.BaseURL)
+	return err == nil && strings.EqualFold(u.Host, base.Host)
+}
+
+// checkExternalLink asks another site for a link, falling back on GET for
+// the servers that don't do HEAD
+func checkExternalLink(client *http.Client, link string) error {
+	resp, err := client.Head(link)
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		resp.Body.Close()
+		resp, err = client.Get(link)
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// CheckLinks renders every post and returns the links in it that lead
+// nowhere. Links to other sites are only checked, once each, if external
+func CheckLinks(external bool) []Problem {
+	var problems []Problem
+	posts, err := GetAllPosts()
+	if err != nil {
+		return []Problem{{strings.Join(config.ContentDirs, ", "), fmt.Sprintf("can't load posts: %v", err)}}
+	}
+
+	site := newSiteLinks(posts)
+	client := &http.Client{Timeout: linkCheckTimeout}
+	checked := map[string]error{}
+	for _, post := range posts {
+		file := post.Filename
+		base := &url.URL{Path: postURL(post)}
+		body := string(post.Body)
+		if config.CDNURL != "" {
+			// Images on the CDN are still the files under static/
+			body = strings.ReplaceAll(body, `"`+cdnURL("/static/"), `"/static/`)
+		}
+
+		for _, match := range hrefPattern.FindAllStringSubmatch(body, -1) {
+			link := match[1]
+			u, err := url.Parse(link)
+			if err != nil {
+				problems = append(problems, Problem{file, fmt.Sprintf("%s isn't a valid link", link)})
+				continue
+			}
+
+			switch {
+			case u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https":
+				// mailto: and the like
+			case u.Host == "" && u.Path == "":
+				// Anchors in the same page
+			case isInternalLink(u):
+				path := base.ResolveReference(u).Path
+				if !site.resolves(path) {
+					problems = append(problems, Problem{file, fmt.Sprintf("%s doesn't lead anywhere", link)})
+				}
+			case external:
+				if _, ok := checked[link]; !ok {
+					checked[link] = checkExternalLink(client, link)
+				}
+				if err := checked[link]; err != nil {
+					problems = append(problems, Problem{file, fmt.Sprintf("%s is broken: %v", link, err)})
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// RunCheckLinks checks the links of every post, prints the broken ones per
+// file and returns the exit code
+func RunCheckLinks(w io.Writer, external bool) int {
+	return reportProblems(w, CheckLinks(external))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want []string
+	}{
+		{name: "post", link: "/post/other"},
+		{name: "post with extension", link: "/post/other.md"},
+		{name: "relative", link: "other"},
+		{name: "page", link: "/about"},
+		{name: "tag", link: "/tag/go"},
+		{name: "tag feed", link: "/tag/go/feed.xml"},
+		{name: "static file", link: "/static/css/style.css"},
+		{name: "reserved path", link: "/api"},
+		{name: "anchor", link: "#top"},
+		{name: "mailto", link: "mailto:me@example.com"},
+		{name: "own host", link: "http://example.com/post/other"},
+		{name: "external", link: "https://elsewhere.example/missing"},
+		{name: "missing post", link: "/post/nothing", want: []string{"/post/nothing doesn't lead anywhere"}},
+		{name: "missing page", link: "/nothing", want: []string{"/nothing doesn't lead anywhere"}},
+		{name: "missing tag", link: "/tag/rust", want: []string{"/tag/rust doesn't lead anywhere"}},
+		{name: "missing static file", link: "/static/nothing.css", want: []string{"/static/nothing.css doesn't lead anywhere"}},
+		{name: "own host, missing post", link: "http://example.com/post/nothing", want: []string{"http://example.com/post/nothing doesn't lead anywhere"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{
+				"post.md":  "title: Post\ndate: 2024-01-01T00:00:00Z\n---\nSee [this](" + tt.link + ").\n",
+				"other.md": "title: Other\ndate: 2024-01-02T00:00:00Z\ntags: go\n---\nOther\n",
+				"about.md": "title: About\ntype: page\n---\nAbout\n",
+			}, func(c *Config) { c.BaseURL = "http://example.com" })
+
+			var got []string
+			for _, p := range CheckLinks(false) {
+				if p.File != "post.md" {
+					t.Errorf("problem in %s: %s", p.File, p.Message)
+				}
+				got = append(got, p.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got problems %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckExternalLinks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/get-only":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	withPosts(t, map[string]string{
+		"post.md": "title: Post\ndate: 2024-01-01T00:00:00Z\n---\n" +
+			"[ok](" + server.URL + "/ok) [get](" + server.URL + "/get-only) [gone](" + server.URL + "/gone)\n",
+	}, nil)
+
+	if problems := CheckLinks(false); len(problems) != 0 {
+		t.Errorf("external links were checked without asking: %v", problems)
+	}
+
+	var out bytes.Buffer
+	if code := RunCheckLinks(&out, true); code == 0 {
+		t.Errorf("a broken external link passed: %s", out.String())
+	}
+	want := server.URL + "/gone is broken: 404 Not Found"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("output has no %q: %s", want, out.String())
+	}
+	if strings.Contains(out.String(), "/ok") || strings.Contains(out.String(), "/get-only") {
+		t.Errorf("a working link was reported: %s", out.String())
+	}
+}
+
+func TestRunCheckLinks(t *testing.T) {
+	withPosts(t, map[string]string{
+		"post.md": "title: Post\ndate: 2024-01-01T00:00:00Z\n---\n[Fine](/) and [broken](/post/nothing)\n",
+	}, nil)
+
+	var out bytes.Buffer
+	if code := RunCheckLinks(&out, false); code == 0 {
+		t.Error("a broken link passed")
+	}
+	if want := "post.md:\n  - /post/nothing doesn't lead anywhere\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// monthNames and dayNames localize dates in the languages that have them.
+// Anything else keeps the English names
+var monthNames = map[string][12]string{
+	"it": {"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+	"fr": {"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+	"de": {"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+	"es": {"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+}
+
+var dayNames = map[string][7]string{
+	"it": {"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+	"fr": {"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+	"de": {"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+	"es": {"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+}
+
+// primaryLanguage returns the language of a locale, "it" for "it-IT"
+func primaryLanguage(locale string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	return lang
+}
+
+// abbreviate returns the first three letters of a name
+func abbreviate(name string) string {
+	runes := []rune(name)
+	if len(runes) > 3 {
+		runes = runes[:3]
+	}
+	return string(runes)
+}
+
+// formatLocalized formats t with a Go time layout, with the month and day
+// names in the language of locale
+func formatLocalized(t time.Time, layout string, locale string) string {
+	out := t.Format(layout)
+	lang := primaryLanguage(locale)
+
+	if months, ok := monthNames[lang]; ok {
+		name := months[t.Month()-1]
+		if strings.Contains(layout, "January") {
+			out = strings.Replace(out, t.Month().String(), name, 1)
+		} else if strings.Contains(layout, "Jan") {
+			out = strings.Replace(out, t.Month().String()[:3], abbreviate(name), 1)
+		}
+	}
+	if days, ok := dayNames[lang]; ok {
+		name := days[t.Weekday()]
+		if strings.Contains(layout, "Monday") {
+			out = strings.Replace(out, t.Weekday().String(), name, 1)
+		} else if strings.Contains(layout, "Mon") {
+			out = strings.Replace(out, t.Weekday().String()[:3], abbreviate(name), 1)
+		}
+	}
+	return out
+}
+
+// translate returns the translation of s for locale, falling back on the
+// language of the locale and then on s itself
+func translate(s string, locale string) string {
+	for _, l := range []string{strings.ToLower(locale), primaryLanguage(locale)} {
+		for key, strs := range config.Translations {
+			if strings.ToLower(key) == l {
+				if t, ok := strs[s]; ok {
+					return t
+				}
+			}
+		}
+	}
+	return s
+}
+
+// T translates a template string into the site language
+func T(s string) string {
+	return translate(s, config.Language)
+}
+
+// matchLocale picks the configured locale that best matches an
+// Accept-Language header, or the site language if none does
+func matchLocale(header string, locales []string) string {
+	type pref struct {
+		tag string
+		q   float64
+	}
+
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			prefs = append(prefs, pref{tag, q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	for _, p := range prefs {
+		for _, locale := range locales {
+			if strings.EqualFold(p.tag, locale) {
+				return locale
+			}
+		}
+		for _, locale := range locales {
+			if primaryLanguage(p.tag) == primaryLanguage(locale) {
+				return locale
+			}
+		}
+	}
+	return config.Language
+}
+
+// requestLocale returns the locale to use for a request. Only sites with
+// locales configured look at Accept-Language
+func requestLocale(w http.ResponseWriter, r *http.Request) string {
+	if len(config.Locales) == 0 {
+		return config.Language
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	return matchLocale(r.Header.Get("Accept-Language"), config.Locales)
+}
+
+// localizedTemplate returns tmpl with dates and strings in the locale of the
+// request. Without locales configured it's tmpl itself
+func localizedTemplate(w http.ResponseWriter, r *http.Request, tmpl *template.Template) (*template.Template, error) {
+	if len(config.Locales) == 0 {
+		return tmpl, nil
+	}
+
+	locale := requestLocale(w, r)
+	localized, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	formatDate := func(format string, dateStr string) string {
+		t, err := time.Parse(time.RFC3339, dateStr)
+		if err != nil {
+			return FormatDate(format, dateStr)
+		}
+		return formatLocalized(inSiteZone(t), format, locale)
+	}
+	return localized.Funcs(template.FuncMap{
+		"FormatDate": formatDate,
+		"PostDate":   func(dateStr string) string { return formatDate(config.DateFormat, dateStr) },
+		"T":          func(s string) string { return translate(s, locale) },
+	}), nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"container/list"
+	"expvar"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	renderCacheHits   = expvar.NewInt("render_cache_hits")
+	renderCacheMisses = expvar.NewInt("render_cache_misses")
+)
+
+// RenderCache is a bounded LRU of rendered posts. Entries are keyed by file
+// name and only used while the file, and the snippets it includes, keep the
+// same size and modification time
+type RenderCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type renderEntry struct {
+	filename string
+	modTime  time.Time
+	size     int64
+	includes string
+	post     Post
+}
+
+// renderCache caches the posts rendered by parsePost
+var renderCache = NewRenderCache(256)
+
+// NewRenderCache creates a cache holding at most capacity posts, 0 disables it
+func NewRenderCache(capacity int) *RenderCache {
+	return &RenderCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+// Get returns the cached post for a file if the file hasn't changed since
+func (c *RenderCache) Get(filename string, info os.FileInfo) (Post, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[filename]
+	if !ok {
+		renderCacheMisses.Add(1)
+		return Post{}, false
+	}
+
+	entry := el.Value.(*renderEntry)
+	if !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() || !entry.includesUnchanged() {
+		c.ll.Remove(el)
+		delete(c.items, filename)
+		renderCacheMisses.Add(1)
+		return Post{}, false
+	}
+
+	c.ll.MoveToFront(el)
+	renderCacheHits.Add(1)
+	return entry.post, true
+}
+
+// includesUnchanged reports whether the snippets the post includes are still
+// the ones it was rendered with
+func (e *renderEntry) includesUnchanged() bool {
+	if len(e.post.Includes) == 0 {
+		return true
+	}
+	stamp, err := filesStamp(e.post.Includes)
+	return err == nil && stamp == e.includes
+}
+
+// Add caches the post rendered from a file, evicting the least recently used
+// posts when the cache is full
+func (c *RenderCache) Add(filename string, info os.FileInfo, post Post) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	includes, err := filesStamp(post.Includes)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &renderEntry{filename: filename, modTime: info.ModTime(), size: info.Size(), includes: includes, post: post}
+	if el, ok := c.items[filename]; ok {
+		el.Value = entry
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[filename] = c.ll.PushFront(entry)
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*renderEntry).filename)
+	}
+}
+
+// Remove drops the post rendered from a file, if it's cached
+func (c *RenderCache) Remove(filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[filename]; ok {
+		c.ll.Remove(el)
+		delete(c.items, filename)
+	}
+}
@@ -1,18 +1,31 @@
 package main
 
 import (
-	"encoding/xml"
+	"context"
+	"errors"
+	"expvar"
+	"flag"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
 	"github.com/gorilla/mux"
 	"gopkg.in/yaml.v2"
@@ -22,35 +35,406 @@ import (
 type Post struct {
 	Filename string
 	Title    string `yaml:"title"`
+	Summary  string `yaml:"summary"`
+	Author   string `yaml:"author"`
 	Date     string `yaml:"date"`
 	Tags     string `yaml:"tags"`
 	Draft    bool   `yaml:"draft"`
+	Pinned   bool   `yaml:"pinned"`
+	Featured bool   `yaml:"featured"`
+	Weight   int    `yaml:"weight"`
+	Image    string `yaml:"image"`
+	ImageAlt string `yaml:"image_alt"`
+	Expires  string `yaml:"expires"`
+	Updated  string `yaml:"updated"`
+	Type     string `yaml:"type"`
+	Template string `yaml:"template"`
+	Layout   string `yaml:"layout"`
+	Comments *bool  `yaml:"comments"`
+	License  string `yaml:"license"`
 	Body     template.HTML
+
+	// Canonical is where the post was first published, if elsewhere. It's
+	// the post's canonical URL instead of its own
+	Canonical string `yaml:"canonical"`
+
+	// Lang is the language the post is written in, defaulting to the site's,
+	// and TranslationKey links the translations of the same post together
+	Lang           string `yaml:"lang"`
+	TranslationKey string `yaml:"translation_key"`
+
+	// ModTime is when the file was last modified, and WasUpdated whether
+	// that's meaningfully later than the publication date
+	ModTime    time.Time
+	WasUpdated bool
+
+	// HasMermaid is whether the post has diagrams to render
+	HasMermaid bool
+
+	// ImageWidth and ImageHeight are the size of the featured image, when
+	// it's a local one
+	ImageWidth  int
+	ImageHeight int
+
+	// Words is how many words the text of the post has
+	Words int
+
+	// ContentHash is the hash of the raw file, see contentHash
+	ContentHash string
+
+	// Includes are the snippet files the post pulls in, which it has to be
+	// rendered again after they change
+	Includes []string
+}
+
+// Post types. Only posts are listed in the index and the feeds, pages are
+// served at the root and notes are only reachable by their URL
+const (
+	TypePost = "post"
+	TypePage = "page"
+	TypeNote = "note"
+)
+
+// postLayouts are the variations of the post template a post can pick with
+// `layout: <name>`, which end up as a class of the article
+var postLayouts = map[string]bool{
+	"wide": true,
+}
+
+// reservedSlugs are the paths at the root that can't be used by pages
+var reservedSlugs = map[string]bool{
+	"admin":                true,
+	"api":                  true,
+	"drafts":               true,
+	"post":                 true,
+	"tag":                  true,
+	"static":               true,
+	"feed.xml":             true,
+	"feeds.opml":           true,
+	"latest":               true,
+	"sitemap.xml":          true,
+	"healthz":              true,
+	"metrics":              true,
+	"stats":                true,
+	"search-index.json":    true,
+	"favicon.ico":          true,
+	"apple-touch-icon.png": true,
+	"site.webmanifest":     true,
+}
+
+// Slug returns the filename of the post without its extension
+func (p Post) Slug() string {
+	return strings.TrimSuffix(p.Filename, filepath.Ext(p.Filename))
+}
+
+// footnoteRefPattern matches the footnote markers in the text of a post
+var footnoteRefPattern = regexp.MustCompile(`<sup class="footnote-ref"[^>]*>.*?</sup>`)
+
+// Description returns the summary of the post, or else the text of its first
+// two paragraphs like the feed, cut to the configured length for the
+// description meta tag
+func (p Post) Description() string {
+	text := p.Summary
+	if text == "" {
+		body, _, _ := strings.Cut(string(p.Body), `<div class="footnotes"`)
+		paragraphs := strings.SplitN(body, "</p>", 3)
+		if len(paragraphs) > 2 {
+			paragraphs = paragraphs[:2]
+		}
+		text = StripHTML(footnoteRefPattern.ReplaceAllString(strings.Join(paragraphs, " "), ""))
+	}
+	return truncateWords(text, config.DescriptionLength)
+}
+
+// ReadingTime returns how many minutes it takes to read the post at the
+// configured reading speed, at least one
+func (p Post) ReadingTime() int {
+	minutes := (p.Words + config.WordsPerMinute - 1) / config.WordsPerMinute
+	if minutes < 1 {
+		return 1
+	}
+	return minutes
+}
+
+// TemplateName returns the templates the post is rendered with: the ones it
+// asks for, else post-<type>.html if there's one for its type, else post.html
+func (p Post) TemplateName() string {
+	if p.Template != "" {
+		return "post-" + p.Template
+	}
+	if _, ok := templateFiles["post-"+p.Type]; ok {
+		return "post-" + p.Type
+	}
+	return "post"
+}
+
+// postURL returns the path a post is served at. Pages live at the root, and
+// everything else follows the permalink pattern if there's one, or else is
+// under /post/, without the extension with pretty URLs. With the "add"
+// trailing slash policy they end with a slash
+func postURL(post Post) string {
+	url := "/post/" + post.Filename
+	if post.Type == TypePage {
+		url = "/" + post.Slug()
+	} else if permalink, ok := expandPermalink(config.Permalink, post); ok {
+		url = permalink
+	} else if config.PrettyURLs {
+		url = "/post/" + post.Slug()
+	}
+	if trailingSlashPolicy == TrailingSlashAdd && slashable(url) {
+		url += "/"
+	}
+	return url
+}
+
+// hasPostExtension reports whether name ends with one of the post extensions
+func hasPostExtension(name string) bool {
+	for _, ext := range config.PostExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimPostExtension returns name without its post extension, if it has one
+func trimPostExtension(name string) string {
+	for _, ext := range config.PostExtensions {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+// TagList returns the comma separated tags of the post as a slice
+func (p Post) TagList() []string {
+	tags := []string{}
+	for _, tag := range strings.Split(p.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
 }
 
-// RSS represents the RSS feed
-type RSS struct {
-	XMLName xml.Name `xml:"rss"`
-	Version string   `xml:"version,attr"`
-	Channel Channel  `xml:"channel"`
+// HasTag reports whether the post is tagged with tag, ignoring case
+func (p Post) HasTag(tag string) bool {
+	for _, t := range p.TagList() {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPublished reports whether the post is visible at the given time: drafts
+// never are, and posts with an expiry date stop being visible once it passes
+func (p Post) IsPublished(now time.Time) bool {
+	if p.Draft {
+		return false
+	}
+
+	if p.Expires != "" {
+		expires, err := time.Parse(time.RFC3339, p.Expires)
+		if err != nil {
+			log.Printf("Error parsing expiry date of %s: %v", p.Filename, err)
+			return true
+		}
+		if !now.Before(expires) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsFuture reports whether the post is dated after now. Such posts are still
+// published, but don't count as the latest or as updated until their date
+func (p Post) IsFuture(now time.Time) bool {
+	date, err := time.Parse(time.RFC3339, p.Date)
+	return err == nil && date.After(now)
+}
+
+// devMode serves the posts that aren't published yet, to preview them
+var devMode bool
+
+// IsVisible reports whether the post can be served on its own: only when
+// it's published, except in dev mode
+func (p Post) IsVisible(now time.Time) bool {
+	return devMode || p.IsPublished(now)
+}
+
+// PublishedPosts filters out the posts that aren't visible at the given time
+func PublishedPosts(posts []Post, now time.Time) []Post {
+	var published []Post
+	for _, post := range posts {
+		if post.IsPublished(now) {
+			published = append(published, post)
+		}
+	}
+	return published
+}
+
+// RecentPosts returns the n most recent of posts that belong in listings, for
+// the templates to show on any page
+func RecentPosts(posts []Post, n int, now time.Time) []Post {
+	if n <= 0 {
+		return nil
+	}
+	// GetAllPosts sorts them by date already
+	listed := ListedPosts(posts, now)
+	if len(listed) > n {
+		listed = listed[:n]
+	}
+	return listed
+}
+
+// UpdatedPosts returns up to n of the posts that were updated after they were
+// published, the most recently updated first. Posts dated after now are left
+// out
+func UpdatedPosts(posts []Post, n int, now time.Time) []Post {
+	if n <= 0 {
+		return nil
+	}
+
+	var updated []Post
+	for _, post := range posts {
+		if post.WasUpdated && !post.IsFuture(now) {
+			updated = append(updated, post)
+		}
+	}
+	sort.SliceStable(updated, func(i, j int) bool {
+		return updated[i].LastUpdated().After(updated[j].LastUpdated())
+	})
+	if len(updated) > n {
+		updated = updated[:n]
+	}
+	return updated
+}
+
+// RelatedPosts returns up to n of the posts sharing tags with post, the ones
+// sharing the most first and the newest first among those. posts has to be
+// sorted by date already, and translations of post are left out
+func RelatedPosts(post Post, posts []Post, n int) []Post {
+	if n <= 0 {
+		return nil
+	}
+
+	type candidate struct {
+		post   Post
+		shared int
+	}
+
+	tags := post.TagList()
+	var candidates []candidate
+	for _, p := range posts {
+		if p.Filename == post.Filename || (post.TranslationKey != "" && p.TranslationKey == post.TranslationKey) {
+			continue
+		}
+		shared := 0
+		for _, tag := range tags {
+			if p.HasTag(tag) {
+				shared++
+			}
+		}
+		if shared > 0 {
+			candidates = append(candidates, candidate{p, shared})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].shared > candidates[j].shared
+	})
+
+	var related []Post
+	for i := 0; i < len(candidates) && i < n; i++ {
+		related = append(related, candidates[i].post)
+	}
+	return related
+}
+
+// Neighbours returns the posts before and after post in the index order, nil
+// at either end or when post isn't in the index at all
+func Neighbours(post Post, listed []Post) (prev *Post, next *Post) {
+	sorted := SortForIndex(listed)
+	for i := range sorted {
+		if sorted[i].Filename != post.Filename {
+			continue
+		}
+		if i > 0 {
+			prev = &sorted[i-1]
+		}
+		if i < len(sorted)-1 {
+			next = &sorted[i+1]
+		}
+		break
+	}
+	return prev, next
+}
+
+// ListedPosts returns the published posts that belong in listings and feeds,
+// leaving out pages and notes
+func ListedPosts(posts []Post, now time.Time) []Post {
+	var listed []Post
+	for _, post := range PublishedPosts(posts, now) {
+		if post.Type == TypePost {
+			listed = append(listed, post)
+		}
+	}
+	return listed
+}
+
+// CommentsEnabled reports whether the comments embed is shown on the post.
+// It is when comments are configured, unless the post opts out
+func (p Post) CommentsEnabled() bool {
+	return config.Comments.ScriptURL != "" && (p.Comments == nil || *p.Comments)
+}
+
+// IsPinned reports whether the post should be listed before the others in the index
+func (p Post) IsPinned() bool {
+	return p.Pinned || p.Featured || p.Weight > 0
+}
+
+// dateLayouts are the layouts dates in the front matter can have besides
+// RFC3339. They have no timezone, so they're in the site's
+var dateLayouts = []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+// parseDate parses a date from the front matter, RFC3339 or one of the
+// zoneless dateLayouts
+func parseDate(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, config.location()); err == nil {
+			return t, nil
+		}
+	}
+	return t, err
 }
 
-// Channel represents the RSS channel
-type Channel struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	Language    string `xml:"language"`
-	Items       []Item `xml:"item"`
+// normalizeDate returns a date from the front matter in RFC3339, which is
+// what everything else expects. Dates that don't parse are left as they are
+// for whatever looks at them to complain
+func normalizeDate(s string) string {
+	if s == "" {
+		return s
+	}
+	t, err := parseDate(s)
+	if err != nil {
+		return s
+	}
+	return t.Format(time.RFC3339)
 }
 
-// Item represents an item in the RSS feed
-type Item struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-	GUID        string `xml:"guid"`
+// inSiteZone returns t in the site's timezone, or as it is without one
+func inSiteZone(t time.Time) time.Time {
+	if config.Location == nil {
+		return t
+	}
+	return t.In(config.Location)
 }
 
 // FormatDate converts a date string in RFC3339 format to a formatted date string
@@ -63,200 +447,788 @@ func FormatDate(format string, dateStr string) string {
 	}
 
 	// Format the time.Time object according to the provided format
-	return t.Format(format)
+	return inSiteZone(t).Format(format)
+}
+
+// PostDate formats a date string in RFC3339 format with the configured date format
+func PostDate(dateStr string) string {
+	return FormatDate(config.DateFormat, dateStr)
+}
+
+// RelativeDate converts a date string in RFC3339 format to a string like "3 days ago"
+func RelativeDate(dateStr string) string {
+	return relativeDate(dateStr, time.Now())
+}
+
+// relativeDate is RelativeDate relative to a given time. Dates in the future
+// or more than a year in the past are formatted as plain dates
+func relativeDate(dateStr string, now time.Time) string {
+	t, err := time.Parse(time.RFC3339, dateStr)
+	if err != nil {
+		log.Printf("Error parsing date: %v", err)
+		return ""
+	}
+
+	d := now.Sub(t)
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+
+	switch {
+	case d < 0 || d >= 365*24*time.Hour:
+		return inSiteZone(t).Format(config.DateFormat)
+	case d < time.Minute:
+		return plural(int(d/time.Second), "second")
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	case d < 30*24*time.Hour:
+		return plural(int(d/(24*time.Hour)), "day")
+	default:
+		return plural(int(d/(30*24*time.Hour)), "month")
+	}
+}
+
+// defaultTrivia is used when no trivia file is available
+var defaultTrivia = []string{
+	"Your beloved ones love you",
+	"Your beloved ones don't love you",
+	"You will feel more intelligent",
+	"You will feel less intelligent",
+	"There is a heaven and you're not going",
+	"There is a heaven and you're going",
+	"There is no heaven but you're not going anyway",
+	"There is no heaven but you're going somewhere else",
+	"Your path to enlightenment is blocked by a cat",
+	"You will get arrested",
+	"Your loneliness will be cured",
+	"Your loneliness will be eternal",
+	"Your loneliness will be cured by a cat",
+	"Your loneliness will be eternal because of a cat",
+}
+
+// trivia holds the sentences Trivia picks from
+var trivia = defaultTrivia
+
+// triviaRand picks the trivia. It's seeded at startup and can be reseeded
+// with SeedTrivia to get a predictable sequence
+var (
+	triviaRand   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	triviaRandMu sync.Mutex
+)
+
+// SeedTrivia reseeds the source used by Trivia
+func SeedTrivia(seed int64) {
+	triviaRandMu.Lock()
+	defer triviaRandMu.Unlock()
+	triviaRand = rand.New(rand.NewSource(seed))
+}
+
+// LoadTrivia reads one trivia per line from a file, skipping empty lines
+func LoadTrivia(filename string) ([]string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no trivia in %s", filename)
+	}
+	return lines, nil
 }
 
 // Trivia returns a random sentence from a list of trivia
 func Trivia() string {
-	trivia := []string{
-		"Your beloved ones love you",
-		"Your beloved ones don't love you",
-		"You will feel more intelligent",
-		"You will feel less intelligent",
-		"There is a heaven and you're not going",
-		"There is a heaven and you're going",
-		"There is no heaven but you're not going anyway",
-		"There is no heaven but you're going somewhere else",
-		"Your path to enlightenment is blocked by a cat",
-		"You will get arrested",
-		"Your loneliness will be cured",
-		"Your loneliness will be eternal",
-		"Your loneliness will be cured by a cat",
-		"Your loneliness will be eternal because of a cat",
-	}
-
-	// Return a random trivia
-	return trivia[time.Now().UnixNano()%int64(len(trivia))]
+	// rand.Rand isn't safe for concurrent use
+	triviaRandMu.Lock()
+	defer triviaRandMu.Unlock()
+	return trivia[triviaRand.Intn(len(trivia))]
 }
 
 // Create a new template.FuncMap and add the FormatDate function
 var funcMap = template.FuncMap{
-	"FormatDate": FormatDate,
-	"Trivia":     Trivia,
+	"FormatDate":   FormatDate,
+	"PostDate":     PostDate,
+	"PostURL":      postURL,
+	"DraftURL":     draftURL,
+	"CDN":          cdnURL,
+	"DateFormat":   func() string { return config.DateFormat },
+	"RelativeDate": RelativeDate,
+	"T":            T,
+	"Trivia":       Trivia,
+	"excerpt":      Excerpt,
 }
 
-// RSSHandler generates the RSS feed
-func RSSHandler(w http.ResponseWriter, r *http.Request) {
-	posts, err := GetAllPosts()
+// contentTypeHTML is set explicitly rather than left to content sniffing
+const contentTypeHTML = "text/html; charset=utf-8"
+
+// templateFiles lists the files making up each page, the layout first
+var templateFiles = map[string][]string{
+	"index":  {"templates/layout.html", "templates/analytics.html", "templates/index.html"},
+	"post":   postTemplateFiles("templates/post.html"),
+	"404":    {"templates/layout.html", "templates/analytics.html", "templates/404.html"},
+	"drafts": {"templates/layout.html", "templates/analytics.html", "templates/drafts.html"},
+}
+
+// postTemplateFiles lists the files making up a post page with the given
+// content template
+func postTemplateFiles(content string) []string {
+	return []string{"templates/layout.html", "templates/analytics.html", "templates/meta.html", content, "templates/comments.html", "templates/license.html", "templates/related.html", "templates/postnav.html", "templates/backlinks.html"}
+}
+
+// registerPostTemplates adds the alternative post templates, the
+// templates/post-<name>.html files, which posts pick with `template: <name>`
+func registerPostTemplates() error {
+	files, err := filepath.Glob("templates/post-*.html")
 	if err != nil {
-		log.Printf("Error getting all posts: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return err
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".html")
+		templateFiles[name] = postTemplateFiles(file)
 	}
+	return nil
+}
 
-	// Filter out drafts
-	var rssItems []Item
-	for _, post := range posts {
-		if !post.Draft {
-			// Extract the first two paragraphs
-			paragraphs := strings.Split(string(post.Body), "</p>")
-			description := ""
-			for i, paragraph := range paragraphs {
-				if i < 2 {
-					description += paragraph + "</p>"
-				}
-			}
+// templates holds the parsed templates of each page, see LoadTemplates
+var templates map[string]*template.Template
 
-			rssItems = append(rssItems, Item{
-				Title:       post.Title,
-				Link:        fmt.Sprintf("http://%s/post/%s", r.Host, post.Filename),
-				Description: description,
-				PubDate:     FormatDate(time.RFC1123, post.Date),
-				GUID:        post.Filename,
-			})
+// parseTemplates parses the templates of a page
+func parseTemplates(name string) (*template.Template, error) {
+	return template.New("layout.html").Funcs(funcMap).ParseFiles(templateFiles[name]...)
+}
+
+// pageTemplate returns the templates of a page. In dev mode they're parsed
+// again on every request, so edits show up without a restart, falling back on
+// the ones parsed at start if they're broken
+func pageTemplate(name string) *template.Template {
+	if devMode {
+		tmpl, err := parseTemplates(name)
+		if err == nil {
+			return tmpl
 		}
+		log.Printf("Error reloading the %s templates, using the ones parsed at start: %v", name, err)
 	}
+	return templates[name]
+}
 
-	rssFeed := RSS{
-		Version: "2.0",
-		Channel: Channel{
-			Title:       "io.",
-			Link:        "http://io.myyc.dev",
-			Description: "io.myyc.dev",
-			Language:    "en-gb",
-			Items:       rssItems,
-		},
+// LoadTemplates parses the templates of every page. All the missing files are
+// reported at once rather than one at a time
+func LoadTemplates() (map[string]*template.Template, error) {
+	seen := map[string]bool{}
+	var missing []string
+	for _, files := range templateFiles {
+		for _, file := range files {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+			if _, err := os.Stat(file); err != nil {
+				missing = append(missing, file)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing templates: %s", strings.Join(missing, ", "))
 	}
 
-	w.Header().Set("Content-Type", "text/xml")
-	w.Header().Set("Content-Disposition", "inline")
-	if err := xml.NewEncoder(w).Encode(rssFeed); err != nil {
-		log.Printf("Error encoding RSS feed: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	parsed := map[string]*template.Template{}
+	for name := range templateFiles {
+		tmpl, err := parseTemplates(name)
+		if err != nil {
+			return nil, err
+		}
+		parsed[name] = tmpl
 	}
+	return parsed, nil
 }
 
-// Updated GetAllPosts function
+// GetAllPosts returns all the posts sorted by date in descending order. Posts
+// are only parsed again when something in posts/ changes
 func GetAllPosts() ([]Post, error) {
-	var posts []Post
-	files, err := filepath.Glob("posts/*.md")
+	posts, _, err := getAllPosts()
+	return posts, err
+}
+
+// globPosts lists the post files in every content directory, in order
+func globPosts() ([]string, error) {
+	return globPostFiles(config.ContentDirs)
+}
+
+// globPostFiles lists the post files in the given directories, in order
+func globPostFiles(dirs []string) ([]string, error) {
+	var files []string
+	for _, dir := range dirs {
+		for _, ext := range config.PostExtensions {
+			matches, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	}
+	return files, nil
+}
+
+// uniqueSlugs makes sure no two posts share a slug, which happens with the
+// same name in two content directories or with two extensions
+func uniqueSlugs(files []string) error {
+	seen := map[string]string{}
+	for _, file := range files {
+		slug := trimPostExtension(filepath.Base(file))
+		if other, ok := seen[slug]; ok {
+			return fmt.Errorf("%s and %s have the same slug", other, file)
+		}
+		seen[slug] = file
+	}
+	return nil
+}
+
+// findPostFile returns the path of a post file, looking in the content
+// directories in order. A slug without extension is looked up with each of
+// the post extensions. Names can't point outside of the directories
+func findPostFile(name string) (string, error) {
+	return findPostFileIn(config.ContentDirs, name)
+}
+
+// findPostFileIn is findPostFile for the given directories
+func findPostFileIn(dirs []string, name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", os.ErrNotExist
+	}
+
+	names := []string{name}
+	if !hasPostExtension(name) {
+		names = nil
+		for _, ext := range config.PostExtensions {
+			names = append(names, name+ext)
+		}
+	}
+
+	for _, dir := range dirs {
+		for _, filename := range names {
+			file := filepath.Join(dir, filename)
+			if _, err := os.Stat(file); err == nil {
+				return file, nil
+			}
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// getAllPosts is GetAllPosts, also returning the stamp of the posts directory
+// so that callers can cache whatever they derive from the posts
+func getAllPosts() ([]Post, string, error) {
+	files, err := globPosts()
 	if err != nil {
 		log.Printf("Error finding posts: %v", err)
-		return nil, err
+		return nil, "", err
+	}
+	if err := uniqueSlugs(files); err != nil {
+		log.Printf("Error loading posts: %v", err)
+		return nil, "", err
 	}
 
-	for _, file := range files {
-		log.Printf("Reading file: %s", file)
-		post, err := parsePost(file)
-		if err != nil {
-			log.Printf("Error parsing post %s: %v", file, err)
+	stamp, err := postsStamp(files)
+	if err != nil {
+		log.Printf("Error reading posts: %v", err)
+		return nil, "", err
+	}
+
+	// Wiki-links are resolved every time, as posts get published and expire
+	// without their files changing
+	if posts, ok := postCache.Get(stamp); ok {
+		return resolveAllWikiLinks(posts, time.Now(), false), stamp, nil
+	}
+
+	posts, err := loadPosts(files)
+	if err != nil {
+		log.Printf("Error loading posts: %v", err)
+		return nil, "", err
+	}
+	if len(files) == 0 {
+		log.Printf("No posts found in %s, the index will show the empty message", strings.Join(config.ContentDirs, ", "))
+	}
+	// Posts loaded for the first time aren't news, changes after that are
+	if previous := postCache.Set(stamp, posts); previous != nil && len(config.Webhooks.URLs) > 0 {
+		notifyWebhooks(postChanges(previous, posts, time.Now()))
+	}
+	return resolveAllWikiLinks(posts, time.Now(), true), stamp, nil
+}
+
+// warmCache parses every post before the server starts, rather than on the
+// first request
+func warmCache() error {
+	_, err := GetAllPosts()
+	return err
+}
+
+// sortByDate sorts posts by date in descending order
+func sortByDate(posts []Post) {
+	sort.Slice(posts, func(i, j int) bool {
+		return strings.Compare(posts[i].Date, posts[j].Date) > 0
+	})
+}
+
+// loadPosts parses the given files with a pool of workers. Files that can't
+// be parsed are skipped, unless strict parsing is on, in which case the error
+// of the first of them is returned
+func loadPosts(files []string) ([]Post, error) {
+	workers := config.ParseWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	type result struct {
+		post Post
+		err  error
+	}
+	results := make([]result, len(files))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				log.Printf("Reading file: %s", files[j])
+				post, err := parsePost(files[j])
+				results[j] = result{post, err}
+			}
+		}()
+	}
+	for j := range files {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+
+	var posts []Post
+	failed := 0
+	for j, res := range results {
+		file := files[j]
+		if errors.Is(res.err, errPostTooLarge) {
+			// Already logged, and never fatal
+			failed++
+			continue
+		}
+		if res.err != nil {
+			if config.StrictParsing {
+				return nil, fmt.Errorf("parsing %s: %w", file, res.err)
+			}
+			log.Printf("Error parsing post %s: %v", file, res.err)
+			failed++
 			continue
 		}
 
+		post := res.post
 		post.Filename = filepath.Base(file)
+		if post.Type == TypePage && reservedSlugs[post.Slug()] {
+			log.Printf("Warning: page %s is shadowed by a built-in route and can't be reached", file)
+		}
 		posts = append(posts, post)
 	}
 
-	// Sort posts by date in descending order
-	sort.Slice(posts, func(i, j int) bool {
-		return strings.Compare(posts[i].Date, posts[j].Date) > 0
-	})
+	sortByDate(posts)
 
-	log.Printf("Total posts found: %d", len(posts))
+	log.Printf("Total posts found: %d, skipped: %d", len(posts), failed)
 	return posts, nil
 }
 
-// GetPost retrieves a single post by filename
-func GetPost(filename string) (Post, error) {
-	file := filepath.Join("posts", filepath.Clean(filename))
+// SortForIndex orders posts for the index: pinned posts first, by weight
+// (highest first) and then by date, followed by everything else by date.
+// Dates are in the configured direction, while GetAllPosts stays strictly
+// newest first so the feed is unaffected.
+func SortForIndex(posts []Post) []Post {
+	sorted := make([]Post, len(posts))
+	copy(sorted, posts)
 
-	if !strings.HasPrefix(file, "posts") {
-		return Post{}, os.ErrNotExist
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.IsPinned() != b.IsPinned() {
+			return a.IsPinned()
+		}
+		if a.IsPinned() && a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		if config.Sort == SortAsc {
+			return strings.Compare(a.Date, b.Date) < 0
+		}
+		return strings.Compare(a.Date, b.Date) > 0
+	})
+
+	return sorted
+}
+
+// GetPost retrieves a single post by filename, or by slug whatever its
+// extension
+func GetPost(name string) (Post, error) {
+	return getPostIn(config.ContentDirs, name)
+}
+
+// getPostIn is GetPost for the given directories
+func getPostIn(dirs []string, name string) (Post, error) {
+	file, err := findPostFileIn(dirs, name)
+	if err != nil {
+		return Post{}, err
 	}
 
-	if _, err := os.Stat(file); os.IsNotExist(err) {
+	// Assuming parsePost reads the file and parses it into a Post struct
+	post, err := parsePost(file)
+	if errors.Is(err, errPostTooLarge) || errors.Is(err, errUnknownTemplate) {
+		// These posts are skipped everywhere, so they don't exist
 		return Post{}, os.ErrNotExist
 	}
+	post.Filename = filepath.Base(file)
+	return post, err
+}
 
-	// Assuming parsePost reads the file and parses it into a Post struct
-	return parsePost(file)
+// LastUpdated returns when the post was last updated: the updated date of its
+// front matter, or else when its file was last modified
+func (p Post) LastUpdated() time.Time {
+	if t, err := time.Parse(time.RFC3339, p.Updated); err == nil {
+		return inSiteZone(t)
+	}
+	return inSiteZone(p.ModTime)
+}
+
+// wasUpdated reports whether a post last updated at modTime was edited after
+// the publication date, ignoring differences under the configured threshold
+func wasUpdated(dateStr string, modTime time.Time) bool {
+	date, err := time.Parse(time.RFC3339, dateStr)
+	if err != nil {
+		return false
+	}
+	return modTime.Sub(date) > config.UpdatedThreshold
 }
 
-// parsePost reads a Markdown file, parses its YAML front matter and Markdown content, then returns a Post struct
+// errPostTooLarge is returned for files bigger than the configured maximum
+var errPostTooLarge = errors.New("post too large")
+
+// errUnknownTemplate is returned for posts asking for a template that doesn't exist
+var errUnknownTemplate = errors.New("unknown template")
+
+// readPostFile reads a whole file, giving up if it turns out to be larger than
+// the maximum post size, e.g. because it grew after being checked
+func readPostFile(filename string) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readPost(f)
+}
+
+// readPost reads a whole post, giving up as soon as it's larger than the
+// maximum post size
+func readPost(r io.Reader) ([]byte, error) {
+	if config.MaxPostSize <= 0 {
+		return io.ReadAll(r)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r, config.MaxPostSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > config.MaxPostSize {
+		return nil, errPostTooLarge
+	}
+	return content, nil
+}
+
+// parsePost returns the post in a Markdown file, rendering it only if it isn't
+// in the render cache already
 func parsePost(filename string) (Post, error) {
-	var post Post = Post{
-		Draft: false,
+	info, err := os.Stat(filename)
+	if err != nil {
+		log.Printf("Error reading file %s: %v", filename, err)
+		return Post{}, err
+	}
+
+	if config.MaxPostSize > 0 && info.Size() > config.MaxPostSize {
+		log.Printf("Warning: skipping %s, %d bytes is more than the maximum of %d", filename, info.Size(), config.MaxPostSize)
+		return Post{}, errPostTooLarge
+	}
+
+	if post, ok := renderCache.Get(filename, info); ok {
+		return post, nil
+	}
+
+	post, err := parsePostFile(filename)
+	if err == nil {
+		post.ModTime = info.ModTime()
+		post.WasUpdated = wasUpdated(post.Date, post.LastUpdated())
+		renderCache.Add(filename, info, post)
 	}
+	return post, err
+}
 
+// parsePostFile reads a Markdown file, parses its YAML front matter and Markdown content, then returns a Post struct
+func parsePostFile(filename string) (Post, error) {
 	// Read the Markdown file content
-	content, err := os.ReadFile(filename)
+	content, err := readPostFile(filename)
 	if err != nil {
 		log.Printf("Error reading file %s: %v", filename, err)
-		return post, err
+		return Post{Filename: filepath.Base(filename)}, err
+	}
+
+	return parsePostBytes(filename, content)
+}
+
+// parseFrontMatter parses the YAML front matter of a post, returning the post
+// without a body and the Markdown after the front matter
+func parseFrontMatter(filename string, content []byte) (Post, string, error) {
+	var post Post = Post{
+		Filename:    filepath.Base(filename),
+		Draft:       false,
+		Type:        TypePost,
+		ContentHash: contentHash(content),
 	}
 
 	// Split the content into YAML front matter and Markdown body
 	parts := strings.SplitN(string(content), "\n---\n", 2)
 	if len(parts) < 2 {
 		log.Printf("Error: File %s does not contain valid front matter", filename)
-		return post, fmt.Errorf("invalid front matter")
+		return post, "", fmt.Errorf("invalid front matter")
 	}
 
 	// Parse the YAML front matter
-	err = yaml.Unmarshal([]byte(parts[0]), &post)
+	err := yaml.Unmarshal([]byte(parts[0]), &post)
 	if err != nil {
 		log.Printf("Error parsing YAML in file %s: %v", filename, err)
+		return post, "", err
+	}
+
+	post.Date = normalizeDate(post.Date)
+	post.Expires = normalizeDate(post.Expires)
+	post.Updated = normalizeDate(post.Updated)
+
+	// An empty front matter resets everything, the default type included
+	if post.Type == "" {
+		post.Type = TypePost
+	}
+	if post.Type != TypePost && post.Type != TypePage && post.Type != TypeNote {
+		log.Printf("Error: File %s has an unknown type %q", filename, post.Type)
+		return post, "", fmt.Errorf("unknown post type %q", post.Type)
+	}
+
+	if u, err := url.Parse(post.Canonical); post.Canonical != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		log.Printf("Error: File %s has an invalid canonical URL %q", filename, post.Canonical)
+		return post, "", fmt.Errorf("invalid canonical URL %q, it must be an absolute http or https URL", post.Canonical)
+	}
+
+	if _, ok := templateFiles["post-"+post.Template]; post.Template != "" && !ok {
+		log.Printf("Error: File %s asks for an unknown template %q", filename, post.Template)
+		return post, "", fmt.Errorf("%w %q, there's no templates/post-%s.html", errUnknownTemplate, post.Template, post.Template)
+	}
+
+	if post.Layout != "" && !postLayouts[post.Layout] {
+		log.Printf("Warning: File %s asks for an unknown layout %q, using the default one", filename, post.Layout)
+		post.Layout = ""
+	}
+
+	if post.Lang == "" {
+		post.Lang = config.Language
+	}
+	return post, parts[1], nil
+}
+
+// parsePostBytes parses the YAML front matter and Markdown content of a post.
+// filename needn't exist, it only names the post and its footnote anchors
+func parsePostBytes(filename string, content []byte) (Post, error) {
+	post, markdownSource, err := parseFrontMatter(filename, content)
+	if err != nil {
+		return post, err
+	}
+
+	// Splice in the snippets before anything else looks at the Markdown
+	source, includes, err := expandIncludes(markdownSource, 0)
+	if err != nil {
+		log.Printf("Error expanding includes in file %s: %v", filename, err)
 		return post, err
 	}
+	post.Includes = includes
+	source = expandWikiLinks(source)
 
-	// Setup the Markdown parser with footnote extension
-	extensions := parser.CommonExtensions | parser.Footnotes
-	mdParser := parser.NewWithExtensions(extensions)
+	// Setup the Markdown parser with the configured extensions
+	mdParser := parser.NewWithExtensions(markdownExtensions())
 
 	// Convert Markdown to HTML with footnote support
-	html := markdown.ToHTML([]byte(parts[1]), mdParser, nil)
-	post.Body = template.HTML(html)
+	doc := mdParser.Parse([]byte(expandCallouts(moveFenceAttributes(source))))
+	offsetHeadings(doc, config.HeadingOffset)
+	if config.Emoji {
+		renderEmoji(doc)
+	}
+	renderCallouts(doc)
+	markDecorativeImages(doc)
+	rewriteImages(doc)
+	if err := renderCodeOptions(doc); err != nil {
+		log.Printf("Error in the code blocks of file %s: %v", filename, err)
+		return post, err
+	}
+	post.HasMermaid = hasMermaid(doc)
+	post.ImageWidth, post.ImageHeight = imageSize(post.ImageURL())
+
+	prefix := footnotePrefix(filename)
+	body := wrapFootnotes(string(markdown.Render(doc, newRenderer(prefix))), prefix)
+
+	// Only build the table of contents if the post asks for it
+	if strings.Contains(source, tocMarker) {
+		body = insertTOC(body, renderTOC(doc))
+	}
+	post.Body = template.HTML(body)
+	post.Words = len(strings.Fields(StripHTML(body)))
 
 	return post, nil
 }
 
+// footnotePrefix returns the prefix used for the footnote anchors of a post so
+// that ids stay unique even if several posts end up on the same page
+func footnotePrefix(filename string) string {
+	base := filepath.Base(filename)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + "-"
+}
+
+// smartypantsFlags are the renderer flags for curly quotes, dashes and the like
+const smartypantsFlags = html.Smartypants | html.SmartypantsFractions | html.SmartypantsDashes | html.SmartypantsLatexDashes
+
+// newRenderer creates the HTML renderer used for post bodies
+func newRenderer(prefix string) *html.Renderer {
+	flags := html.CommonFlags | html.FootnoteReturnLinks
+	if !config.SmartTypography {
+		flags &^= smartypantsFlags
+	}
+	return html.NewRenderer(html.RendererOptions{
+		Flags:                      flags,
+		FootnoteAnchorPrefix:       prefix,
+		FootnoteReturnLinkContents: "&#8617;&#xfe0e;",
+		RenderNodeHook:             renderNode,
+	})
+}
+
+// renderNode is the render hook for everything rendered differently from the
+// defaults
+func renderNode(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	for _, hook := range []html.RenderNodeFunc{renderCalloutNode, renderMermaidNode, renderCodeNode} {
+		if status, ok := hook(w, node, entering); ok {
+			return status, true
+		}
+	}
+	return ast.GoToNext, false
+}
+
+// wrapFootnotes gives the footnotes container an id so it can be linked and
+// styled, and the footnote markers the id and text of their footnote
+func wrapFootnotes(body string, prefix string) string {
+	body = strings.Replace(body, `<div class="footnotes">`,
+		fmt.Sprintf(`<div class="footnotes" id="%sfootnotes">`, prefix), 1)
+	return addFootnoteData(body)
+}
+
+// footnotePattern matches the footnotes at the end of a post
+var footnotePattern = regexp.MustCompile(`(?s)<li id="(fn:[^"]+)">(.*?)</li>`)
+
+// footnoteReturnPattern matches the links back to the text in the footnotes
+var footnoteReturnPattern = regexp.MustCompile(`<a class="footnote-return"[^>]*>.*?</a>`)
+
+// footnoteMarkerPattern matches the opening tag of a footnote marker
+var footnoteMarkerPattern = regexp.MustCompile(`<sup class="footnote-ref" id="fnref:([^"]+)">`)
+
+// addFootnoteData gives the footnote markers a data-footnote attribute with the
+// id of their footnote, and data-footnote-text with its text, for scripts to
+// show them in place. The links to the footnotes work as they did without one
+func addFootnoteData(body string) string {
+	notes := map[string]string{}
+	for _, m := range footnotePattern.FindAllStringSubmatch(body, -1) {
+		notes[m[1]] = StripHTML(footnoteReturnPattern.ReplaceAllString(m[2], ""))
+	}
+	if len(notes) == 0 {
+		return body
+	}
+
+	return footnoteMarkerPattern.ReplaceAllStringFunc(body, func(marker string) string {
+		id := "fn:" + footnoteMarkerPattern.FindStringSubmatch(marker)[1]
+		text, ok := notes[id]
+		if !ok {
+			return marker
+		}
+		return strings.TrimSuffix(marker, ">") +
+			fmt.Sprintf(` data-footnote="%s" data-footnote-text="%s">`, template.HTMLEscapeString(id), template.HTMLEscapeString(text))
+	})
+}
+
+// loadIntro renders the index_file, shown on the index above the posts. No
+// file means no intro. Its wiki-links point at the given posts
+func loadIntro(posts []Post) template.HTML {
+	if config.IndexFile == "" {
+		return ""
+	}
+	if _, err := os.Stat(config.IndexFile); os.IsNotExist(err) {
+		return ""
+	}
+	intro, err := parsePost(config.IndexFile)
+	if err != nil {
+		log.Printf("Error parsing %s: %v", config.IndexFile, err)
+		return ""
+	}
+	body, _ := resolveWikiLinks(intro.Body, publishedBySlug(posts, time.Now()))
+	return body
+}
+
 // IndexHandler handles the index page
 func IndexHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.New("layout.html").Funcs(funcMap).ParseFiles("templates/layout.html", "templates/index.html")
+	tmpl, err := localizedTemplate(w, r, pageTemplate("index"))
 	if err != nil {
-		log.Printf("Error parsing templates: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Printf("Error localizing templates: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	posts, err := GetAllPosts()
 	if err != nil {
 		log.Printf("Error getting all posts: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	data := struct {
-		IsHome bool
-		Posts  []Post
-	}{
-		IsHome: true,
-		Posts:  posts,
+	// An optional ?lang= only lists the posts written in that language
+	lang := r.URL.Query().Get("lang")
+	listed := ListedPosts(posts, time.Now())
+	if lang != "" {
+		listed = PostsInLanguage(listed, lang)
 	}
 
+	if lang == "" {
+		lang = config.Language
+	}
+
+	data := newPageData(r, false, posts)
+	data.IsHome = true
+	data.Lang = lang
+	data.IntroHTML = loadIntro(posts)
+	data.Posts = SortForIndex(listed)
+	data.Empty = len(listed) == 0
+	data.Updated = UpdatedPosts(listed, config.UpdatedPosts, time.Now())
+	data.EmptyMessage = config.EmptyMessage
+
+	w.Header().Set("Content-Type", contentTypeHTML)
 	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
 		log.Printf("Error executing template: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
 
@@ -264,47 +1236,359 @@ func IndexHandler(w http.ResponseWriter, r *http.Request) {
 func PostHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	title := vars["title"]
-	tmpl, err := template.New("layout.html").Funcs(funcMap).ParseFiles("templates/layout.html", "templates/post.html")
+
+	post, err := GetPost(title)
+	if os.IsNotExist(err) || (err == nil && !post.IsVisible(time.Now())) {
+		log.Printf("Post not found: %s", title)
+		postNotFound(w, r, title)
+		return
+	} else if err != nil {
+		log.Printf("Error getting post: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Both forms work, but only one is canonical. The trailing slash, if
+	// any, is already taken care of
+	if url := postURL(post); strings.TrimSuffix(url, "/") != r.URL.Path && post.Type != TypePage {
+		http.Redirect(w, r, url, http.StatusMovedPermanently)
+		return
+	}
+
+	renderPost(w, r, post)
+}
+
+// LatestHandler renders the most recent published post, so that /latest
+// always shows the newest one. Posts dated in the future don't count yet
+func LatestHandler(w http.ResponseWriter, r *http.Request) {
+	posts, err := GetAllPosts()
 	if err != nil {
-		log.Printf("Error parsing templates: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Printf("Error getting all posts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	post, err := GetPost(title)
-	if os.IsNotExist(err) {
-		log.Printf("Post not found: %s", title)
+	now := time.Now()
+	for _, post := range ListedPosts(posts, now) {
+		if post.IsFuture(now) {
+			continue
+		}
+		renderPost(w, r, post)
+		return
+	}
+	log.Printf("No published posts to show at /latest")
+	http.NotFound(w, r)
+}
+
+// healthPath is where load balancers and the like check the server is up
+const healthPath = "/healthz"
+
+// HealthHandler tells whoever asks that the server is up
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, "ok\n")
+}
+
+// PageHandler handles pages, which live at the root rather than under /post/
+func PageHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+
+	post, err := GetPost(slug)
+	if os.IsNotExist(err) || (err == nil && (!post.IsVisible(time.Now()) || post.Type != TypePage)) {
+		log.Printf("Page not found: %s", slug)
 		http.NotFound(w, r)
 		return
 	} else if err != nil {
-		log.Printf("Error getting post: %v", err)
+		log.Printf("Error getting page: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	data := struct {
-		IsHome bool
-		Post   Post
-	}{
-		IsHome: false,
-		Post:   post,
+	renderPost(w, r, post)
+}
+
+// renderPost renders a single post, page or note
+func renderPost(w http.ResponseWriter, r *http.Request, post Post) {
+	tmpl, err := localizedTemplate(w, r, pageTemplate(post.TemplateName()))
+	if err != nil {
+		log.Printf("Error localizing templates: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	ogImage := post.ImageURL()
+	if ogImage == "" {
+		ogImage = ogImageURL(post)
+	}
+
+	posts, err := GetAllPosts()
+	if err != nil {
+		log.Printf("Error getting all posts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
+	post.Body, _ = resolveWikiLinks(post.Body, publishedBySlug(posts, time.Now()))
+	translations := Translations(post, PublishedPosts(posts, time.Now()))
+	draft := !post.IsPublished(time.Now())
 
+	data := newPageData(r, draft, posts)
+	data.Lang = post.Lang
+	data.Description = post.Description()
+	data.Canonical = data.BaseURL + postURL(post)
+	if post.Canonical != "" {
+		data.Canonical = post.Canonical
+	}
+	data.Watermark = config.DraftWatermark
+	data.Post = post
+	data.OGType = "article"
+	data.OGTitle = post.Title
+	data.OGImage = absoluteURL(data.BaseURL, cdnURL(ogImage))
+	data.Comments = config.Comments
+	data.Translations = translations
+	data.Related = RelatedPosts(post, ListedPosts(posts, time.Now()), config.RelatedPosts)
+	data.Backlinks = Backlinks(post, time.Now())
+	data.Prev, data.Next = Neighbours(post, ListedPosts(posts, time.Now()))
+	data.Alternates = alternates(data.BaseURL, post, translations)
+	data.MermaidURL = config.MermaidURL
+
+	w.Header().Set("Content-Type", contentTypeHTML)
 	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
 		log.Printf("Error executing template: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
 
-func main() {
+// routes sets up the public router and, withAdmin, the one of the admin
+// address, which takes over the operational routes. It's nil otherwise
+func routes(withAdmin bool, debugVars bool, metricsEnabled bool) (*mux.Router, *mux.Router) {
 	r := mux.NewRouter()
 	r.HandleFunc("/", IndexHandler).Methods("GET")
 	r.HandleFunc("/post/{title}", PostHandler).Methods("GET")
+	r.HandleFunc("/post/{title}/og.png", OGImageHandler).Methods("GET")
+	r.Handle("/post/{title}/meta", CORS(http.HandlerFunc(PostMetaHandler))).Methods("GET", "OPTIONS")
 	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("static/"))))
 	r.HandleFunc("/feed.xml", RSSHandler).Methods("GET") // Add this line
+	r.HandleFunc("/latest", LatestHandler).Methods("GET")
+	r.HandleFunc("/tag/{tag}", TagHandler).Methods("GET")
+	r.HandleFunc("/tag/{tag}/feed.xml", TagFeedHandler).Methods("GET")
+	r.HandleFunc("/feeds.opml", OPMLHandler).Methods("GET")
+	r.HandleFunc("/search-index.json", SearchIndexHandler).Methods("GET")
+	r.HandleFunc("/sitemap.xml", SitemapHandler).Methods("GET")
+	r.HandleFunc("/sitemap-{page:[0-9]+}.xml", SitemapPageHandler).Methods("GET")
+	for name := range siteIcons {
+		r.HandleFunc("/"+name, IconHandler(name)).Methods("GET")
+	}
+	r.HandleFunc("/site.webmanifest", ManifestHandler).Methods("GET")
+	if devMode {
+		r.HandleFunc("/preview", PreviewHandler).Methods("POST")
+	}
+
+	api := r.PathPrefix("/api").Subrouter()
+	api.Use(CORS)
+	api.HandleFunc("/posts", PostsAPIHandler).Methods("GET", "OPTIONS")
 
-	log.Println("Starting server on :8081")
-	if err := http.ListenAndServe(":8081", r); err != nil {
-		log.Fatalf("could not start server: %s\n", err)
+	// With an admin address the operational routes move there, all of them
+	var admin *mux.Router
+	if withAdmin {
+		admin = mux.NewRouter()
+		admin.HandleFunc(healthPath, HealthHandler).Methods("GET", "HEAD")
+		admin.HandleFunc(metricsPath, MetricsHandler).Methods("GET")
+		admin.Handle("/stats", expvar.Handler()).Methods("GET")
+	} else {
+		r.HandleFunc(healthPath, HealthHandler).Methods("GET", "HEAD")
+		if debugVars {
+			r.Handle("/debug/vars", expvar.Handler()).Methods("GET")
+		}
+		if metricsEnabled {
+			r.HandleFunc(metricsPath, MetricsHandler).Methods("GET")
+		}
+	}
+	if config.Admin.Password != "" {
+		router := r
+		if admin != nil {
+			router = admin
+		}
+		router.Handle("/admin/reload/{slug}", BasicAuth(http.HandlerFunc(ReloadPostHandler))).Methods("POST")
+	}
+	if config.DraftsDir != "" {
+		registerDrafts(r, admin)
+	}
+	if config.Permalink != "" {
+		r.HandleFunc(permalinkRoute(config.Permalink), PermalinkHandler).Methods("GET")
+		r.HandleFunc(permalinkRoute(config.Permalink)+"/og.png", OGImageHandler).Methods("GET")
+	}
+	// Pages go last so they can never shadow the routes above
+	r.HandleFunc("/{slug}", PageHandler).Methods("GET")
+	r.HandleFunc("/{slug}/og.png", OGImageHandler).Methods("GET")
+
+	return r, admin
+}
+
+func main() {
+	configFile := flag.String("config", "config.yaml", "site configuration file")
+	addr := flag.String("addr", ":8081", "address to listen on")
+	adminAddr := flag.String("admin-addr", "", "address to serve /healthz, /metrics and /stats on instead of the public one")
+	readTimeout := flag.Duration("read-timeout", 10*time.Second, "maximum duration for reading an entire request")
+	readHeaderTimeout := flag.Duration("read-header-timeout", 5*time.Second, "maximum duration for reading request headers")
+	writeTimeout := flag.Duration("write-timeout", 30*time.Second, "maximum duration before timing out writes of a response")
+	idleTimeout := flag.Duration("idle-timeout", 120*time.Second, "maximum time to wait for the next request on a keep-alive connection")
+	maxHeaderBytes := flag.Int("max-header-bytes", 1<<16, "maximum size of request headers in bytes")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "maximum time to wait for open requests when shutting down")
+	triviaFile := flag.String("trivia", "trivia.txt", "file with one trivia per line")
+	trailingSlash := flag.String("trailing-slash", TrailingSlashStrip, "trailing slash policy: strip, add or off")
+	accessLog := flag.String("access-log", AccessLogOff, "access log on stdout: off, common, combined or json")
+	debugVars := flag.Bool("debug-vars", false, "serve runtime and cache counters at /debug/vars")
+	metricsEnabled := flag.Bool("metrics", false, "serve request and cache metrics for Prometheus at /metrics")
+	check := flag.Bool("check", false, "validate posts, templates and configuration, then exit")
+	checkLinks := flag.Bool("check-links", false, "check the links in every post, then exit")
+	checkExternal := flag.Bool("check-external", false, "with -check-links, check the links to other sites too")
+	dev := flag.Bool("dev", false, "serve drafts and expired posts by their URL, marked as drafts, and reload templates on every request")
+	flag.Parse()
+
+	switch *trailingSlash {
+	case TrailingSlashStrip, TrailingSlashAdd, TrailingSlashOff:
+		trailingSlashPolicy = *trailingSlash
+	default:
+		log.Fatalf("invalid trailing slash policy: %s", *trailingSlash)
+	}
+	switch *accessLog {
+	case AccessLogOff, AccessLogCommon, AccessLogCombined, AccessLogJSON:
+	default:
+		log.Fatalf("invalid access log format: %s", *accessLog)
+	}
+
+	if c, err := LoadConfig(*configFile); err == nil {
+		log.Printf("Loaded configuration from %s", *configFile)
+		config = c
+	} else if !os.IsNotExist(err) {
+		log.Fatalf("could not load configuration: %s\n", err)
+	}
+
+	if flag.Arg(0) == "new" {
+		os.Exit(RunNew(os.Stdout, flag.Args()[1:]))
+	}
+
+	renderCache = NewRenderCache(config.RenderCacheSize)
+
+	if *dev {
+		log.Printf("Dev mode, drafts are served: don't do this in production")
+		devMode = true
+	}
+
+	if err := registerPostTemplates(); err != nil {
+		log.Fatalf("could not list templates: %s\n", err)
+	}
+
+	// Before loading the posts, which stops at the first broken one, so that
+	// all the problems are reported at once
+	if *check {
+		os.Exit(RunCheck(os.Stdout))
+	}
+
+	if *checkLinks {
+		os.Exit(RunCheckLinks(os.Stdout, *checkExternal))
+	}
+
+	if err := warmCache(); err != nil {
+		log.Fatalf("could not load posts: %s\n", err)
+	}
+
+	t, err := LoadTemplates()
+	if err != nil {
+		log.Fatalf("could not load templates: %s\n", err)
+	}
+	templates = t
+
+	if lines, err := LoadTrivia(*triviaFile); err == nil {
+		log.Printf("Loaded %d trivia from %s", len(lines), *triviaFile)
+		trivia = lines
+	} else if !os.IsNotExist(err) {
+		log.Printf("Error loading trivia, using the built-in ones: %v", err)
+	}
+
+	r, admin := routes(*adminAddr != "", *debugVars, *metricsEnabled)
+
+	handler := SecurityHeaders(CanonicalHost(TrailingSlash(*trailingSlash, r)))
+	if *metricsEnabled || admin != nil {
+		handler = Metrics(handler)
+	}
+	if *accessLog != AccessLogOff {
+		handler = AccessLog(os.Stdout, *accessLog, handler)
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadTimeout:       *readTimeout,
+		ReadHeaderTimeout: *readHeaderTimeout,
+		WriteTimeout:      *writeTimeout,
+		IdleTimeout:       *idleTimeout,
+		MaxHeaderBytes:    *maxHeaderBytes,
+	}
+
+	servers := []*http.Server{srv}
+	if config.TLS.Enabled() {
+		// The plain HTTP listener answers the ACME challenges and redirects
+		// everything else to HTTPS
+		m := certManager(config.TLS)
+		srv.TLSConfig = m.TLSConfig()
+		servers = append(servers, &http.Server{
+			Addr:              config.TLS.HTTPAddr,
+			Handler:           m.HTTPHandler(nil),
+			ReadTimeout:       *readTimeout,
+			ReadHeaderTimeout: *readHeaderTimeout,
+			WriteTimeout:      *writeTimeout,
+			IdleTimeout:       *idleTimeout,
+			MaxHeaderBytes:    *maxHeaderBytes,
+		})
+	}
+	if admin != nil {
+		servers = append(servers, &http.Server{
+			Addr:              *adminAddr,
+			Handler:           admin,
+			ReadTimeout:       *readTimeout,
+			ReadHeaderTimeout: *readHeaderTimeout,
+			WriteTimeout:      *writeTimeout,
+			IdleTimeout:       *idleTimeout,
+			MaxHeaderBytes:    *maxHeaderBytes,
+		})
+	}
+
+	for _, s := range servers {
+		go func(s *http.Server) {
+			var err error
+			if s.TLSConfig != nil {
+				log.Printf("Starting server on %s with TLS", s.Addr)
+				err = s.ListenAndServeTLS("", "")
+			} else {
+				log.Printf("Starting server on %s", s.Addr)
+				err = s.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				log.Fatalf("could not start server: %s\n", err)
+			}
+		}(s)
+	}
+
+	// Stop taking new connections on a signal and let the open ones finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	log.Printf("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s *http.Server) {
+			defer wg.Done()
+			if err := s.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Error shutting down server on %s: %v", s.Addr, err)
+			}
+		}(s)
 	}
+	wg.Wait()
 }
@@ -0,0 +1,1416 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// withConfig runs a test with the default configuration, changed by set if
+// it isn't nil, and empty caches. Everything is put back once the test is done
+func withConfig(t testing.TB, set func(c *Config)) {
+	t.Helper()
+
+	savedConfig, savedPosts, savedRender := config, postCache, renderCache
+	t.Cleanup(func() {
+		config, postCache, renderCache = savedConfig, savedPosts, savedRender
+	})
+
+	config = DefaultConfig()
+	if set != nil {
+		set(&config)
+	}
+	postCache = &PostCache{}
+	renderCache = NewRenderCache(config.RenderCacheSize)
+}
+
+// writeFiles writes files, by name, to a new temporary directory and returns it
+func writeFiles(t testing.TB, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// withPosts runs a test with the given posts as the only content directory
+func withPosts(t testing.TB, posts map[string]string, set func(c *Config)) {
+	t.Helper()
+
+	dir := writeFiles(t, posts)
+	withConfig(t, func(c *Config) {
+		c.ContentDirs = []string{dir}
+		if set != nil {
+			set(c)
+		}
+	})
+}
+
+// withTemplates parses the templates for the length of a test
+func withTemplates(t testing.TB) {
+	t.Helper()
+
+	saved := templates
+	t.Cleanup(func() { templates = saved })
+
+	parsed, err := LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates = parsed
+}
+
+// serve has handler answer a GET request for path, with the given route
+// variables, and returns the response
+func serve(handler http.HandlerFunc, path string, vars map[string]string) *httptest.ResponseRecorder {
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, path, nil), vars)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+var (
+	idPattern         = regexp.MustCompile(`id="([^"]+)"`)
+	fragmentPattern   = regexp.MustCompile(`href="#([^"]+)"`)
+	footnoteIDPattern = regexp.MustCompile(`id="([^"]*fn[^"]*)"`)
+	canonicalPattern  = regexp.MustCompile(`<link rel="canonical" href="([^"]+)">`)
+)
+
+func TestFootnoteLinksResolve(t *testing.T) {
+	withConfig(t, nil)
+
+	tests := []struct {
+		name     string
+		filename string
+		source   string
+		links    int
+	}{
+		{
+			name:     "one footnote",
+			filename: "one.md",
+			source:   "title: One\n---\nText[^1].\n\n[^1]: The note.\n",
+			links:    2,
+		},
+		{
+			name:     "several footnotes",
+			filename: "several.md",
+			source:   "title: Several\n---\nFirst[^a], second[^b].\n\n[^a]: A.\n[^b]: B.\n",
+			links:    4,
+		},
+		{
+			name:     "named footnote and dashes in the filename",
+			filename: "a-long-name.markdown",
+			source:   "title: Named\n---\nText[^note].\n\n[^note]: The note.\n",
+			links:    2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post, err := parsePostBytes(tt.filename, []byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := string(post.Body)
+
+			ids := map[string]bool{}
+			for _, m := range idPattern.FindAllStringSubmatch(body, -1) {
+				ids[m[1]] = true
+			}
+			links := fragmentPattern.FindAllStringSubmatch(body, -1)
+			if len(links) != tt.links {
+				t.Fatalf("got %d links to anchors, want %d in %s", len(links), tt.links, body)
+			}
+			for _, m := range links {
+				if !ids[m[1]] {
+					t.Errorf("link to #%s has no anchor in %s", m[1], body)
+				}
+			}
+
+			container := footnotePrefix(tt.filename) + "footnotes"
+			if !ids[container] {
+				t.Errorf("no footnotes container with id %q in %s", container, body)
+			}
+		})
+	}
+}
+
+func TestFootnoteIDsUniqueAcrossPosts(t *testing.T) {
+	withConfig(t, nil)
+
+	source := []byte("title: Post\n---\nText[^1].\n\n[^1]: The note.\n")
+	seen := map[string]string{}
+	for _, filename := range []string{"first.md", "second.md"} {
+		post, err := parsePostBytes(filename, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range footnoteIDPattern.FindAllStringSubmatch(string(post.Body), -1) {
+			if other, ok := seen[m[1]]; ok {
+				t.Errorf("id %q is in both %s and %s", m[1], other, filename)
+			}
+			seen[m[1]] = filename
+		}
+	}
+	if len(seen) == 0 {
+		t.Fatal("no footnote ids found")
+	}
+}
+
+func TestFootnoteData(t *testing.T) {
+	withConfig(t, nil)
+
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "one footnote",
+			source: "Text[^1].\n\n[^1]: The note.\n",
+			want: []string{
+				`<sup class="footnote-ref" id="fnref:post-1" data-footnote="fn:post-1" data-footnote-text="The note."><a href="#fn:post-1">1</a></sup>`,
+			},
+		},
+		{
+			name:   "markup in the note",
+			source: "Text[^n].\n\n[^n]: The *note* & <b>more</b>.\n",
+			want: []string{
+				`data-footnote="fn:post-n" data-footnote-text="The note &amp; more."`,
+			},
+		},
+		{
+			name:   "several footnotes",
+			source: "One[^a], two[^b].\n\n[^a]: First.\n[^b]: Second.\n",
+			want: []string{
+				`data-footnote="fn:post-a" data-footnote-text="First."><a href="#fn:post-a">1</a>`,
+				`data-footnote="fn:post-b" data-footnote-text="Second."><a href="#fn:post-b">2</a>`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := renderBody(t, tt.source)
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body has no %q: %s", want, body)
+				}
+			}
+			// The footnotes are still there for the links to point at
+			if !strings.Contains(body, `<div class="footnotes"`) {
+				t.Errorf("the footnotes are gone: %s", body)
+			}
+		})
+	}
+
+	if body := renderBody(t, "No notes, <sup>2</sup>.\n"); strings.Contains(body, "data-footnote") {
+		t.Errorf("a post without footnotes got the attributes: %s", body)
+	}
+}
+
+func TestLoadTrivia(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"trivia.txt": "First\n\n  Second  \nThird\n",
+		"blank.txt":  "\n  \n",
+	})
+
+	tests := []struct {
+		name    string
+		file    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "lines", file: "trivia.txt", want: []string{"First", "Second", "Third"}},
+		{name: "only blank lines", file: "blank.txt", wantErr: true},
+		{name: "missing file", file: "missing.txt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadTrivia(filepath.Join(dir, tt.file))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTriviaFromFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"trivia.txt": "Only this\nOr that\n"})
+	lines, err := LoadTrivia(filepath.Join(dir, "trivia.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := trivia
+	t.Cleanup(func() { trivia = saved })
+	trivia = lines
+
+	for i := 0; i < 20; i++ {
+		if got := Trivia(); got != "Only this" && got != "Or that" {
+			t.Fatalf("Trivia() = %q, not from the file", got)
+		}
+	}
+}
+
+func TestIsPublishedExpiry(t *testing.T) {
+	expires := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		post Post
+		now  time.Time
+		want bool
+	}{
+		{name: "no expiry", post: Post{}, now: expires, want: true},
+		{name: "before expiry", post: Post{Expires: "2024-03-01T12:00:00Z"}, now: expires.Add(-time.Nanosecond), want: true},
+		{name: "at expiry", post: Post{Expires: "2024-03-01T12:00:00Z"}, now: expires, want: false},
+		{name: "after expiry", post: Post{Expires: "2024-03-01T12:00:00Z"}, now: expires.Add(time.Second), want: false},
+		{name: "expiry in another zone", post: Post{Expires: "2024-03-01T13:00:00+01:00"}, now: expires.Add(-time.Second), want: true},
+		{name: "at expiry in another zone", post: Post{Expires: "2024-03-01T13:00:00+01:00"}, now: expires, want: false},
+		{name: "draft before expiry", post: Post{Draft: true, Expires: "2024-03-01T12:00:00Z"}, now: expires.Add(-time.Hour), want: false},
+		{name: "invalid expiry", post: Post{Expires: "soon"}, now: expires, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.post.IsPublished(tt.now); got != tt.want {
+				t.Errorf("IsPublished(%v) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiredPostsLeaveListings(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	posts := []Post{
+		{Filename: "current.md", Type: TypePost, Expires: "2024-03-01T12:00:01Z"},
+		{Filename: "expired.md", Type: TypePost, Expires: "2024-03-01T12:00:00Z"},
+		{Filename: "forever.md", Type: TypePost},
+	}
+
+	var got []string
+	for _, post := range ListedPosts(posts, now) {
+		got = append(got, post.Filename)
+	}
+	want := []string{"current.md", "forever.md"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSeededTrivia(t *testing.T) {
+	savedTrivia, savedRand := trivia, triviaRand
+	t.Cleanup(func() { trivia, triviaRand = savedTrivia, savedRand })
+	trivia = defaultTrivia
+
+	tests := []struct {
+		seed int64
+		want []string
+	}{
+		{seed: 1, want: []string{
+			"Your loneliness will be eternal because of a cat",
+			"You will get arrested",
+			"Your beloved ones don't love you",
+		}},
+		{seed: 42, want: []string{
+			"There is a heaven and you're going",
+			"You will get arrested",
+			"There is a heaven and you're not going",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.seed), func(t *testing.T) {
+			SeedTrivia(tt.seed)
+			for i, want := range tt.want {
+				if got := Trivia(); got != want {
+					t.Errorf("Trivia() #%d = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRelativeDate(t *testing.T) {
+	withConfig(t, nil)
+
+	now := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{ago: 0, want: "0 seconds ago"},
+		{ago: time.Second, want: "1 second ago"},
+		{ago: 45 * time.Second, want: "45 seconds ago"},
+		{ago: time.Minute, want: "1 minute ago"},
+		{ago: 59 * time.Minute, want: "59 minutes ago"},
+		{ago: 3 * time.Hour, want: "3 hours ago"},
+		{ago: 24 * time.Hour, want: "1 day ago"},
+		{ago: 3 * 24 * time.Hour, want: "3 days ago"},
+		{ago: 30 * 24 * time.Hour, want: "1 month ago"},
+		{ago: 200 * 24 * time.Hour, want: "6 months ago"},
+		{ago: 365 * 24 * time.Hour, want: "2023-06-16"},
+		{ago: -time.Hour, want: "2024-06-15"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			date := now.Add(-tt.ago).Format(time.RFC3339)
+			if got := relativeDate(date, now); got != tt.want {
+				t.Errorf("relativeDate(%s) = %q, want %q", date, got, tt.want)
+			}
+		})
+	}
+
+	if got := relativeDate("yesterday", now); got != "" {
+		t.Errorf("invalid date gave %q", got)
+	}
+}
+
+// filenames returns the filenames of posts, in order
+func filenames(posts []Post) []string {
+	var names []string
+	for _, post := range posts {
+		names = append(names, post.Filename)
+	}
+	return names
+}
+
+func TestSortForIndex(t *testing.T) {
+	posts := []Post{
+		{Filename: "b.md", Date: "2024-02-01T00:00:00Z"},
+		{Filename: "c.md", Date: "2024-03-01T00:00:00Z"},
+		{Filename: "a.md", Date: "2024-01-01T00:00:00Z"},
+	}
+
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{sort: SortDesc, want: []string{"c.md", "b.md", "a.md"}},
+		{sort: SortAsc, want: []string{"a.md", "b.md", "c.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.Sort = tt.sort })
+			if got := filenames(SortForIndex(posts)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAscendingSortKeepsPostsNewestFirst(t *testing.T) {
+	withPosts(t, map[string]string{
+		"old.md": "title: Old\ndate: 2024-01-01T00:00:00Z\n---\nOld\n",
+		"new.md": "title: New\ndate: 2024-03-01T00:00:00Z\n---\nNew\n",
+	}, func(c *Config) { c.Sort = SortAsc })
+
+	posts, err := GetAllPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := filenames(posts), []string{"new.md", "old.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAllPosts gave %v, want %v", got, want)
+	}
+	if got, want := filenames(SortForIndex(posts)), []string{"old.md", "new.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("the index got %v, want %v", got, want)
+	}
+}
+
+func TestLoadPostsOrder(t *testing.T) {
+	files := map[string]string{}
+	for i := 1; i <= 20; i++ {
+		files[fmt.Sprintf("post-%02d.md", i)] = fmt.Sprintf("title: Post %d\ndate: 2024-01-%02dT00:00:00Z\n---\nText\n", i, i)
+	}
+	files["broken.md"] = "no front matter"
+
+	for _, workers := range []int{0, 1, 3, 50} {
+		t.Run(fmt.Sprint(workers), func(t *testing.T) {
+			withPosts(t, files, func(c *Config) { c.ParseWorkers = workers })
+
+			paths, err := globPosts()
+			if err != nil {
+				t.Fatal(err)
+			}
+			posts, err := loadPosts(paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(posts) != 20 {
+				t.Fatalf("got %d posts, want 20", len(posts))
+			}
+			for i, post := range posts {
+				if want := fmt.Sprintf("post-%02d.md", 20-i); post.Filename != want {
+					t.Errorf("post %d is %s, want %s", i, post.Filename, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadPostsStrict(t *testing.T) {
+	withPosts(t, map[string]string{
+		"a.md": "title: A\n---\nText\n",
+		"b.md": "no front matter",
+		"c.md": "also no front matter",
+	}, func(c *Config) { c.StrictParsing = true })
+
+	paths, err := globPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadPosts(paths)
+	if err == nil || !strings.Contains(err.Error(), "b.md") {
+		t.Errorf("got error %v, want the one of b.md", err)
+	}
+}
+
+func BenchmarkLoadPosts(b *testing.B) {
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	files := map[string]string{}
+	body := strings.Repeat("Some *text* with a [link](https://example.com) and `code`.\n\n", 50)
+	for i := 0; i < 200; i++ {
+		files[fmt.Sprintf("post-%03d.md", i)] = fmt.Sprintf("title: Post %d\ndate: 2024-01-01T00:00:00Z\ntags: a, b\n---\n# Heading\n\n%s", i, body)
+	}
+
+	for _, workers := range []int{1, 4, 0} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			// Without a render cache every iteration parses every file
+			withPosts(b, files, func(c *Config) {
+				c.ParseWorkers = workers
+				c.RenderCacheSize = 0
+			})
+			paths, err := globPosts()
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := loadPosts(paths); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestWasUpdated(t *testing.T) {
+	date := time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		updated string
+		modTime time.Time
+		want    bool
+	}{
+		{name: "touched days later", modTime: date.Add(48 * time.Hour), want: true},
+		{name: "touched within the threshold", modTime: date.Add(30 * time.Minute), want: false},
+		{name: "touched before the date", modTime: date.Add(-48 * time.Hour), want: false},
+		{name: "updated date later", updated: "2024-02-01", modTime: date, want: true},
+		{name: "updated date within the threshold", updated: "2024-01-01T10:30:00Z", modTime: date.Add(48 * time.Hour), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			front := "title: Post\ndate: 2024-01-01T10:00:00Z\n"
+			if tt.updated != "" {
+				front += "updated: " + tt.updated + "\n"
+			}
+			dir := writeFiles(t, map[string]string{"post.md": front + "---\nText\n"})
+			withConfig(t, nil)
+
+			file := filepath.Join(dir, "post.md")
+			if err := os.Chtimes(file, tt.modTime, tt.modTime); err != nil {
+				t.Fatal(err)
+			}
+			post, err := parsePost(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if post.WasUpdated != tt.want {
+				t.Errorf("WasUpdated = %v, want %v", post.WasUpdated, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentsEmbed(t *testing.T) {
+	tests := []struct {
+		name      string
+		scriptURL string
+		front     string
+		want      bool
+	}{
+		{name: "enabled", scriptURL: "https://comments.example.com/embed.js", want: true},
+		{name: "enabled on the post", scriptURL: "https://comments.example.com/embed.js", front: "comments: true\n", want: true},
+		{name: "disabled on the post", scriptURL: "https://comments.example.com/embed.js", front: "comments: false\n", want: false},
+		{name: "not configured", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{
+				"post.md": "title: Post\ndate: 2024-01-01T00:00:00Z\n" + tt.front + "---\nText\n",
+			}, func(c *Config) { c.Comments.ScriptURL = tt.scriptURL })
+			withTemplates(t)
+
+			rec := serve(PostHandler, "/post/post", map[string]string{"title": "post"})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d", rec.Code)
+			}
+			body := rec.Body.String()
+			if got := strings.Contains(body, `class="comments"`); got != tt.want {
+				t.Errorf("comments embed shown: %v, want %v", got, tt.want)
+			}
+			if got := strings.Contains(body, `data-identifier="post"`); got != tt.want {
+				t.Errorf("embed with the slug as identifier: %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarmCache(t *testing.T) {
+	withPosts(t, map[string]string{
+		"a.md":      "title: A\ndate: 2024-01-01T00:00:00Z\n---\nA\n",
+		"b.md":      "title: B\ndate: 2024-01-02T00:00:00Z\n---\nB\n",
+		"broken.md": "no front matter",
+	}, nil)
+
+	files, err := globPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp, err := postsStamp(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := postCache.Get(stamp); ok {
+		t.Fatal("posts cached before warming the cache")
+	}
+
+	if err := warmCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	posts, ok := postCache.Get(stamp)
+	if !ok {
+		t.Fatal("posts not cached after warming the cache")
+	}
+	if got, want := filenames(posts), []string{"b.md", "a.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cached %v, want %v", got, want)
+	}
+
+	hits := postCacheHits.Value()
+	if _, err := GetAllPosts(); err != nil {
+		t.Fatal(err)
+	}
+	if postCacheHits.Value() != hits+1 {
+		t.Error("the first request after warming the cache missed it")
+	}
+}
+
+func TestWarmCacheDuplicateSlugs(t *testing.T) {
+	withPosts(t, map[string]string{
+		"a.md":       "title: A\n---\nA\n",
+		"a.markdown": "title: Also A\n---\nA\n",
+	}, nil)
+
+	if err := warmCache(); err == nil {
+		t.Error("no error for two posts with the same slug")
+	}
+}
+
+func TestLangAttribute(t *testing.T) {
+	tests := []struct {
+		name  string
+		front string
+		want  string
+	}{
+		{name: "front matter", front: "lang: it\n", want: `<html lang="it">`},
+		{name: "region", front: "lang: pt-BR\n", want: `<html lang="pt-BR">`},
+		{name: "site default", front: "", want: `<html lang="en-gb">`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{
+				"post.md": "title: Post\ndate: 2024-01-01T00:00:00Z\n" + tt.front + "---\nText\n",
+			}, nil)
+			withTemplates(t)
+
+			rec := serve(PostHandler, "/post/post", map[string]string{"title": "post"})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d", rec.Code)
+			}
+			if body := rec.Body.String(); !strings.Contains(body, tt.want) {
+				t.Errorf("page has no %s", tt.want)
+			}
+		})
+	}
+}
+
+func TestPostURLEverywhere(t *testing.T) {
+	tests := []struct {
+		name      string
+		pretty    bool
+		permalink string
+		slash     string
+		want      string
+	}{
+		{name: "pretty", pretty: true, slash: TrailingSlashStrip, want: "/post/hello"},
+		{name: "with extension", pretty: false, slash: TrailingSlashStrip, want: "/post/hello.md"},
+		{name: "permalink", pretty: true, permalink: "/:year/:month/:slug", slash: TrailingSlashStrip, want: "/2024/01/hello"},
+		{name: "trailing slash", pretty: true, slash: TrailingSlashAdd, want: "/post/hello/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{
+				"hello.md": "title: Hello\ndate: 2024-01-15T00:00:00Z\n---\nHello\n",
+			}, func(c *Config) {
+				c.PrettyURLs = tt.pretty
+				c.Permalink = tt.permalink
+			})
+			withTemplates(t)
+			saved := trailingSlashPolicy
+			t.Cleanup(func() { trailingSlashPolicy = saved })
+			trailingSlashPolicy = tt.slash
+
+			want := "http://example.com" + tt.want
+			got := map[string]string{}
+
+			feed := getFeed(t, RSSHandler, "/feed.xml", nil)
+			got["rss"] = feed.Channel.Items[0].Link
+
+			var sitemap URLSet
+			if err := xml.Unmarshal(serve(SitemapHandler, "/sitemap.xml", nil).Body.Bytes(), &sitemap); err != nil {
+				t.Fatal(err)
+			}
+			got["sitemap"] = sitemap.URLs[1].Loc
+
+			var api []APIPost
+			if err := json.Unmarshal(serve(PostsAPIHandler, "/api/posts", nil).Body.Bytes(), &api); err != nil {
+				t.Fatal(err)
+			}
+			got["api"] = "http://example.com" + api[0].URL
+
+			var search []SearchEntry
+			if err := json.Unmarshal(serve(SearchIndexHandler, "/search-index.json", nil).Body.Bytes(), &search); err != nil {
+				t.Fatal(err)
+			}
+			got["search"] = "http://example.com" + search[0].URL
+
+			index := serve(IndexHandler, "/", nil).Body.String()
+			if strings.Contains(index, `href="`+tt.want+`"`) {
+				got["index"] = want
+			}
+
+			post, err := GetPost("hello")
+			if err != nil {
+				t.Fatal(err)
+			}
+			page := serve(func(w http.ResponseWriter, r *http.Request) { renderPost(w, r, post) }, tt.want, nil)
+			if m := canonicalPattern.FindStringSubmatch(page.Body.String()); m != nil {
+				got["canonical"] = m[1]
+			}
+
+			for _, generator := range []string{"rss", "sitemap", "api", "search", "index", "canonical"} {
+				if got[generator] != want {
+					t.Errorf("%s has %q, want %q", generator, got[generator], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPinnedPostsFirst(t *testing.T) {
+	posts := []Post{
+		{Filename: "new.md", Date: "2024-03-01T00:00:00Z"},
+		{Filename: "pinned-new.md", Date: "2024-02-01T00:00:00Z", Pinned: true},
+		{Filename: "middle.md", Date: "2024-01-15T00:00:00Z"},
+		{Filename: "featured-old.md", Date: "2024-01-01T00:00:00Z", Featured: true},
+		{Filename: "heavy-old.md", Date: "2023-01-01T00:00:00Z", Weight: 10},
+	}
+
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{sort: SortDesc, want: []string{"heavy-old.md", "pinned-new.md", "featured-old.md", "new.md", "middle.md"}},
+		{sort: SortAsc, want: []string{"heavy-old.md", "featured-old.md", "pinned-new.md", "middle.md", "new.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.Sort = tt.sort })
+			if got := filenames(SortForIndex(posts)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPinnedPostOnIndexOnly(t *testing.T) {
+	withPosts(t, map[string]string{
+		"old.md": "title: Old\ndate: 2024-01-01T00:00:00Z\npinned: true\n---\nOld\n",
+		"new.md": "title: New\ndate: 2024-03-01T00:00:00Z\n---\nNew\n",
+	}, nil)
+	withTemplates(t)
+
+	index := serve(IndexHandler, "/", nil).Body.String()
+	oldAt, newAt := strings.Index(index, `href="/post/old"`), strings.Index(index, `href="/post/new"`)
+	if oldAt < 0 || newAt < 0 || oldAt > newAt {
+		t.Errorf("the pinned post isn't first on the index: %s", index)
+	}
+
+	feed := getFeed(t, RSSHandler, "/feed.xml", nil)
+	if got := feed.Channel.Items[0].Title; got != "New" {
+		t.Errorf("the feed starts with %q, want the newest post", got)
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	withPosts(t, map[string]string{}, func(c *Config) { c.Admin.Password = "secret" })
+
+	tests := []struct {
+		method string
+		path   string
+		admin  int
+	}{
+		{method: http.MethodGet, path: healthPath, admin: http.StatusOK},
+		{method: http.MethodGet, path: metricsPath, admin: http.StatusOK},
+		{method: http.MethodGet, path: "/stats", admin: http.StatusOK},
+		{method: http.MethodPost, path: "/admin/reload/post", admin: http.StatusUnauthorized},
+	}
+
+	public, admin := routes(true, true, true)
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			public.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("the public router answered %d, want %d", rec.Code, http.StatusNotFound)
+			}
+
+			rec = httptest.NewRecorder()
+			admin.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.admin {
+				t.Errorf("the admin router answered %d, want %d", rec.Code, tt.admin)
+			}
+		})
+	}
+}
+
+func TestNoAdminRoutes(t *testing.T) {
+	withPosts(t, map[string]string{}, nil)
+
+	public, admin := routes(false, false, true)
+	if admin != nil {
+		t.Fatal("an admin router without an admin address")
+	}
+	for _, path := range []string{healthPath, metricsPath} {
+		rec := httptest.NewRecorder()
+		public.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("the public router answered %d for %s", rec.Code, path)
+		}
+	}
+}
+
+func TestCustomTemplate(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"post-gallery.html": `{{ define "content" }}<article class="gallery">{{ .Post.Body }}</article>{{ end }}`,
+	})
+	saved, registered := templateFiles["post-gallery"]
+	t.Cleanup(func() {
+		if registered {
+			templateFiles["post-gallery"] = saved
+		} else {
+			delete(templateFiles, "post-gallery")
+		}
+	})
+	templateFiles["post-gallery"] = postTemplateFiles(filepath.Join(dir, "post-gallery.html"))
+
+	tests := []struct {
+		name     string
+		template string
+		status   int
+		want     string
+	}{
+		{name: "custom", template: "gallery", status: http.StatusOK, want: `<article class="gallery">`},
+		{name: "default", template: "", status: http.StatusOK, want: "<article>"},
+		{name: "unknown", template: "slides", status: http.StatusNotFound, want: "Not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{
+				"photos.md": "title: Photos\ndate: 2024-01-01T00:00:00Z\ntemplate: " + tt.template + "\n---\nPictures\n",
+			}, nil)
+			withTemplates(t)
+
+			rec := serve(PostHandler, "/post/photos", map[string]string{"title": "photos"})
+			if rec.Code != tt.status {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.status)
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("page has no %q: %s", tt.want, body)
+			}
+			// Whatever the template, it's inside the layout
+			if !strings.Contains(body, `<html lang="en-gb">`) {
+				t.Error("page isn't wrapped in the layout")
+			}
+		})
+	}
+}
+
+func TestPostLayout(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout string
+		want   string
+	}{
+		{name: "wide", layout: "wide", want: `<article class="wide">`},
+		{name: "default", layout: "", want: "<article>"},
+		{name: "unknown", layout: "huge", want: "<article>"},
+		{name: "not a class", layout: `wide" onclick="x`, want: "<article>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{
+				"photos.md": "title: Photos\ndate: 2024-01-01T00:00:00Z\nlayout: '" + tt.layout + "'\n---\nPictures\n",
+			}, nil)
+			withTemplates(t)
+
+			rec := serve(PostHandler, "/post/photos", map[string]string{"title": "photos"})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d", rec.Code)
+			}
+			if body := rec.Body.String(); !strings.Contains(body, tt.want) {
+				t.Errorf("page has no %q: %s", tt.want, body)
+			}
+		})
+	}
+}
+
+func TestDraftWatermark(t *testing.T) {
+	tests := []struct {
+		name      string
+		front     string
+		dev       bool
+		watermark string
+		status    int
+		want      bool
+	}{
+		{name: "draft in dev mode", front: "draft: true\n", dev: true, watermark: "DRAFT", status: http.StatusOK, want: true},
+		{name: "expired in dev mode", front: "expires: 2020-01-01T00:00:00Z\n", dev: true, watermark: "DRAFT", status: http.StatusOK, want: true},
+		{name: "published in dev mode", front: "", dev: true, watermark: "DRAFT", status: http.StatusOK, want: false},
+		{name: "watermark disabled", front: "draft: true\n", dev: true, watermark: "", status: http.StatusOK, want: false},
+		{name: "published", front: "", dev: false, watermark: "DRAFT", status: http.StatusOK, want: false},
+		{name: "draft", front: "draft: true\n", dev: false, watermark: "DRAFT", status: http.StatusNotFound, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{
+				"post.md": "title: Post\ndate: 2019-01-01T00:00:00Z\n" + tt.front + "---\nText\n",
+			}, func(c *Config) { c.DraftWatermark = tt.watermark })
+			withTemplates(t)
+			saved := devMode
+			t.Cleanup(func() { devMode = saved })
+			devMode = tt.dev
+
+			rec := serve(PostHandler, "/post/post", map[string]string{"title": "post"})
+			if rec.Code != tt.status {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.status)
+			}
+			if got := strings.Contains(rec.Body.String(), `class="watermark"`); got != tt.want {
+				t.Errorf("watermark shown: %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMixedExtensions(t *testing.T) {
+	posts := map[string]string{
+		"one.md":       "title: One\ndate: 2024-01-01T00:00:00Z\n---\nOne\n",
+		"two.markdown": "title: Two\ndate: 2024-01-02T00:00:00Z\n---\nTwo\n",
+		"three.txt":    "title: Three\ndate: 2024-01-03T00:00:00Z\n---\nThree\n",
+	}
+
+	tests := []struct {
+		name       string
+		extensions []string
+		all        []string
+		found      map[string]string
+		missing    []string
+	}{
+		{
+			name:       "default",
+			extensions: []string{".md", ".markdown"},
+			all:        []string{"two.markdown", "one.md"},
+			found:      map[string]string{"one": "one.md", "two": "two.markdown", "two.markdown": "two.markdown"},
+			missing:    []string{"three", "two.md", "../one.md"},
+		},
+		{
+			name:       "configured",
+			extensions: []string{".txt", ".md"},
+			all:        []string{"three.txt", "one.md"},
+			found:      map[string]string{"three": "three.txt", "one": "one.md"},
+			missing:    []string{"two", "two.markdown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, posts, func(c *Config) { c.PostExtensions = tt.extensions })
+
+			all, err := GetAllPosts()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filenames(all); !reflect.DeepEqual(got, tt.all) {
+				t.Errorf("GetAllPosts gave %v, want %v", got, tt.all)
+			}
+
+			for name, want := range tt.found {
+				post, err := GetPost(name)
+				if err != nil {
+					t.Errorf("GetPost(%q): %v", name, err)
+				} else if post.Filename != want {
+					t.Errorf("GetPost(%q) gave %s, want %s", name, post.Filename, want)
+				}
+			}
+			for _, name := range tt.missing {
+				if _, err := GetPost(name); !os.IsNotExist(err) {
+					t.Errorf("GetPost(%q) gave error %v, want none found", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePostBytes(t *testing.T) {
+	withConfig(t, nil)
+
+	tests := []struct {
+		name    string
+		content string
+		want    Post
+		body    string
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			content: "title: Hello\ndate: 2024-01-02\ntags: go, web\n---\nSome *text*.\n",
+			want:    Post{Title: "Hello", Date: "2024-01-02T00:00:00Z", Tags: "go, web", Type: TypePost, Lang: "en-gb", Words: 2},
+			body:    "<p>Some <em>text</em>.</p>\n",
+		},
+		{
+			name:    "empty body",
+			content: "title: Empty\n---\n",
+			want:    Post{Title: "Empty", Type: TypePost, Lang: "en-gb"},
+			body:    "",
+		},
+		{
+			name:    "empty front matter",
+			content: "\n---\nText\n",
+			want:    Post{Type: TypePost, Lang: "en-gb", Words: 1},
+			body:    "<p>Text</p>\n",
+		},
+		{name: "missing front matter", content: "# Just Markdown\n\nText\n", wantErr: true},
+		{name: "empty file", content: "", wantErr: true},
+		{name: "invalid front matter", content: "title: [unclosed\n---\nText\n", wantErr: true},
+		{name: "unknown type", content: "title: Odd\ntype: essay\n---\nText\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post, err := parsePostBytes("posts/hello.md", []byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if string(post.Body) != tt.body {
+				t.Errorf("got body %q, want %q", post.Body, tt.body)
+			}
+			if post.Filename != "hello.md" || post.ContentHash != contentHash([]byte(tt.content)) {
+				t.Errorf("got filename %q and hash %q", post.Filename, post.ContentHash)
+			}
+			post.Body, post.Filename, post.ContentHash = "", "", ""
+			if !reflect.DeepEqual(post, tt.want) {
+				t.Errorf("got %+v, want %+v", post, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	tests := []struct {
+		words int
+		wpm   int
+		want  int
+	}{
+		{0, 200, 1},
+		{150, 200, 1},
+		{200, 200, 1},
+		{201, 200, 2},
+		{1000, 200, 5},
+		{1000, 100, 10},
+		{1000, 500, 2},
+		{1000, 1001, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d words at %d", tt.words, tt.wpm), func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.WordsPerMinute = tt.wpm })
+
+			if got := (Post{Words: tt.words}).ReadingTime(); got != tt.want {
+				t.Errorf("ReadingTime() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordsPerMinuteConfig(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		want    int
+		wantErr bool
+	}{
+		{"title: Blog\n", 200, false},
+		{"words_per_minute: 120\n", 120, false},
+		{"words_per_minute: 0\n", 0, true},
+		{"words_per_minute: -5\n", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimSpace(tt.yaml), func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"config.yaml": tt.yaml})
+
+			c, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("LoadConfig() accepted words_per_minute %d", c.WordsPerMinute)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.WordsPerMinute != tt.want {
+				t.Errorf("WordsPerMinute = %d, want %d", c.WordsPerMinute, tt.want)
+			}
+		})
+	}
+}
+
+func TestListSizes(t *testing.T) {
+	withConfig(t, nil)
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	post := func(name, date, tags string) Post {
+		return Post{Filename: name + ".md", Title: name, Date: date, Tags: tags, Type: TypePost}
+	}
+	current := post("current", "2024-01-05T00:00:00Z", "go, web")
+	posts := []Post{
+		current,
+		post("both", "2024-01-04T00:00:00Z", "go, web"),
+		post("go", "2024-01-03T00:00:00Z", "go"),
+		post("other", "2024-01-02T00:00:00Z", "cooking"),
+	}
+
+	tests := []struct {
+		n       int
+		recent  []string
+		related []string
+	}{
+		{n: 0, recent: nil, related: nil},
+		{n: -1, recent: nil, related: nil},
+		{n: 1, recent: []string{"current.md"}, related: []string{"both.md"}},
+		{n: 2, recent: []string{"current.md", "both.md"}, related: []string{"both.md", "go.md"}},
+		{n: 10, recent: []string{"current.md", "both.md", "go.md", "other.md"}, related: []string{"both.md", "go.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.n), func(t *testing.T) {
+			if got := filenames(RecentPosts(posts, tt.n, now)); !reflect.DeepEqual(got, tt.recent) {
+				t.Errorf("RecentPosts() = %v, want %v", got, tt.recent)
+			}
+			if got := filenames(RelatedPosts(current, posts, tt.n)); !reflect.DeepEqual(got, tt.related) {
+				t.Errorf("RelatedPosts() = %v, want %v", got, tt.related)
+			}
+		})
+	}
+
+	if got := RecentPosts(nil, 5, now); len(got) != 0 {
+		t.Errorf("RecentPosts() of no posts = %v", filenames(got))
+	}
+	if got := RelatedPosts(current, []Post{current}, 5); len(got) != 0 {
+		t.Errorf("RelatedPosts() of a lone post = %v", filenames(got))
+	}
+}
+
+func TestIndexIntro(t *testing.T) {
+	introDir := writeFiles(t, map[string]string{
+		"index.md": "title: Welcome\n---\nHi, I write about *Go*. Start anywhere.\n",
+	})
+
+	tests := []struct {
+		name      string
+		indexFile string
+		want      string
+	}{
+		{
+			name:      "intro",
+			indexFile: filepath.Join(introDir, "index.md"),
+			want:      `<div class="intro"><p>Hi, I write about <em>Go</em>. Start anywhere.</p>`,
+		},
+		{name: "missing file", indexFile: filepath.Join(introDir, "missing.md")},
+		{name: "no index file", indexFile: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{
+				"hello.md": "title: Hello\ndate: 2024-01-01T00:00:00Z\n---\nHello\n",
+			}, func(c *Config) { c.IndexFile = tt.indexFile })
+			withTemplates(t)
+
+			rec := serve(IndexHandler, "/", nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d", rec.Code)
+			}
+			index := rec.Body.String()
+			if !strings.Contains(index, `href="/post/hello"`) {
+				t.Errorf("the index doesn't list the posts: %s", index)
+			}
+			if tt.want == "" {
+				if strings.Contains(index, `class="intro"`) {
+					t.Errorf("the index has an intro: %s", index)
+				}
+			} else if !strings.Contains(index, tt.want) {
+				t.Errorf("index has no %q: %s", tt.want, index)
+			}
+		})
+	}
+}
+
+func TestSiteTimezone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		location   *time.Location
+		date       string
+		normalized string
+		formatted  string
+	}{
+		{
+			name:       "zoneless date and time",
+			location:   newYork,
+			date:       "2024-01-15 09:30",
+			normalized: "2024-01-15T09:30:00-05:00",
+			formatted:  "15 Jan 2024 09:30 EST",
+		},
+		{
+			name:       "zoneless date in summer",
+			location:   newYork,
+			date:       "2024-07-15",
+			normalized: "2024-07-15T00:00:00-04:00",
+			formatted:  "15 Jul 2024 00:00 EDT",
+		},
+		{
+			name:       "date with a zone",
+			location:   newYork,
+			date:       "2024-01-15T02:00:00Z",
+			normalized: "2024-01-15T02:00:00Z",
+			formatted:  "14 Jan 2024 21:00 EST",
+		},
+		{
+			name:       "no site timezone",
+			date:       "2024-01-15 09:30",
+			normalized: "2024-01-15T09:30:00Z",
+			formatted:  "15 Jan 2024 09:30 UTC",
+		},
+		{
+			name:       "invalid date",
+			location:   newYork,
+			date:       "yesterday",
+			normalized: "yesterday",
+			formatted:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.Location = tt.location })
+
+			post, err := parsePostBytes("post.md", []byte("title: Post\ndate: "+tt.date+"\n---\nText\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if post.Date != tt.normalized {
+				t.Errorf("got date %q, want %q", post.Date, tt.normalized)
+			}
+			if got := FormatDate("02 Jan 2006 15:04 MST", post.Date); got != tt.formatted {
+				t.Errorf("FormatDate() = %q, want %q", got, tt.formatted)
+			}
+		})
+	}
+}
+
+func TestTimezoneConfig(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"good.yaml": "timezone: Europe/Rome\n",
+		"bad.yaml":  "timezone: Europe/Nowhere\n",
+	})
+
+	c, err := LoadConfig(filepath.Join(dir, "good.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Location == nil || c.Location.String() != "Europe/Rome" {
+		t.Errorf("got location %v", c.Location)
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "bad.yaml")); err == nil {
+		t.Error("an unknown timezone was accepted")
+	}
+}
+
+func TestTemplateReload(t *testing.T) {
+	original, err := os.ReadFile("templates/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(original), `{{ define "content" }}`, `{{ define "content" }}<p class="edited">Edited</p>`, 1)
+	if edited == string(original) {
+		t.Fatal("can't edit templates/index.html")
+	}
+
+	tests := []struct {
+		name string
+		dev  bool
+		edit string
+		want bool
+	}{
+		{name: "dev", dev: true, edit: edited, want: true},
+		{name: "production", dev: false, edit: edited, want: false},
+		{name: "broken edit in dev", dev: true, edit: `{{ define "content" }}{{ if }}{{ end }}`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"index.html": string(original)})
+			index := filepath.Join(dir, "index.html")
+
+			savedFiles, savedDev := templateFiles["index"], devMode
+			t.Cleanup(func() { templateFiles["index"], devMode = savedFiles, savedDev })
+			templateFiles["index"] = []string{"templates/layout.html", "templates/analytics.html", index}
+			devMode = tt.dev
+
+			withPosts(t, map[string]string{}, nil)
+			withTemplates(t)
+
+			if err := os.WriteFile(index, []byte(tt.edit), 0644); err != nil {
+				t.Fatal(err)
+			}
+			rec := serve(IndexHandler, "/", nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d", rec.Code)
+			}
+			if got := strings.Contains(rec.Body.String(), `<p class="edited">Edited</p>`); got != tt.want {
+				t.Errorf("edit shown: %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalOverride(t *testing.T) {
+	tests := []struct {
+		name      string
+		canonical string
+		want      string
+		invalid   bool
+	}{
+		{name: "own URL", canonical: "", want: "http://example.com/post/post"},
+		{name: "override", canonical: "https://elsewhere.example/2024/post", want: "https://elsewhere.example/2024/post"},
+		{name: "relative", canonical: "/2024/post", invalid: true},
+		{name: "other scheme", canonical: "ftp://elsewhere.example/post", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := "title: Post\ndate: 2024-01-01T00:00:00Z\ncanonical: " + tt.canonical + "\n---\nText\n"
+			withPosts(t, map[string]string{"post.md": source}, nil)
+			withTemplates(t)
+
+			if tt.invalid {
+				if _, err := parsePostBytes("post.md", []byte(source)); err == nil {
+					t.Errorf("canonical %q was accepted", tt.canonical)
+				}
+				return
+			}
+
+			rec := serve(PostHandler, "/post/post", map[string]string{"title": "post"})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d", rec.Code)
+			}
+			page := rec.Body.String()
+			m := canonicalPattern.FindStringSubmatch(page)
+			if m == nil || m[1] != tt.want {
+				t.Errorf("got canonical %v, want %q", m, tt.want)
+			}
+			note := `Originally published at <a href="` + tt.canonical + `">`
+			if hasNote := strings.Contains(page, note); hasNote != (tt.canonical != "") {
+				t.Errorf("note shown: %v, for canonical %q", hasNote, tt.canonical)
+			}
+
+			// Everywhere else the post is linked to on the site
+			feed := getFeed(t, RSSHandler, "/feed.xml", nil)
+			if link := feed.Channel.Items[0].Link; link != "http://example.com/post/post" {
+				t.Errorf("the feed links to %s", link)
+			}
+		})
+	}
+}
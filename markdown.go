@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// markdownExtensionNames maps the names used in the configuration to the
+// parser extensions
+var markdownExtensionNames = map[string]parser.Extensions{
+	"no_intra_emphasis":    parser.NoIntraEmphasis,
+	"tables":               parser.Tables,
+	"fenced_code":          parser.FencedCode,
+	"autolink":             parser.Autolink,
+	"strikethrough":        parser.Strikethrough,
+	"space_headings":       parser.SpaceHeadings,
+	"hard_line_break":      parser.HardLineBreak,
+	"footnotes":            parser.Footnotes,
+	"heading_ids":          parser.HeadingIDs,
+	"auto_heading_ids":     parser.AutoHeadingIDs,
+	"backslash_line_break": parser.BackslashLineBreak,
+	"definition_lists":     parser.DefinitionLists,
+	"mathjax":              parser.MathJax,
+	"super_subscript":      parser.SuperSubscript,
+}
+
+// defaultMarkdownExtensions are the extensions used unless configured otherwise
+var defaultMarkdownExtensions = []string{
+	"no_intra_emphasis",
+	"tables",
+	"fenced_code",
+	"autolink",
+	"strikethrough",
+	"space_headings",
+	"heading_ids",
+	"backslash_line_break",
+	"definition_lists",
+	"mathjax",
+	"footnotes",
+	"auto_heading_ids",
+}
+
+// ParseMarkdownExtensions turns a list of extension names into parser extensions
+func ParseMarkdownExtensions(names []string) (parser.Extensions, error) {
+	var extensions parser.Extensions
+	for _, name := range names {
+		ext, ok := markdownExtensionNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown markdown extension: %s", name)
+		}
+		extensions |= ext
+	}
+	return extensions, nil
+}
+
+// markdownExtensions returns the configured parser extensions
+func markdownExtensions() parser.Extensions {
+	// The configuration is validated when loaded, so the error can't happen
+	extensions, _ := ParseMarkdownExtensions(config.MarkdownExtensions)
+	return extensions
+}
+
+// offsetHeadings moves every heading of a parsed document n levels down, so #
+// becomes <h2> with an offset of 1. Nothing goes below <h6>
+func offsetHeadings(doc ast.Node, n int) {
+	if n <= 0 {
+		return
+	}
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if heading, ok := node.(*ast.Heading); ok && entering {
+			heading.Level += n
+			if heading.Level > 6 {
+				heading.Level = 6
+			}
+		}
+		return ast.GoToNext
+	})
+}
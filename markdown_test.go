@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// headingPattern matches the opening tag of a heading, capturing its level
+var headingPattern = regexp.MustCompile(`<h([1-6])[ >]`)
+
+func TestDefinitionLists(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions []string
+		source     string
+		want       string
+	}{
+		{
+			name:   "term and definition",
+			source: "Term\n: The definition.\n",
+			want:   "<dl>\n<dt>Term</dt>\n<dd>The definition.</dd>\n</dl>",
+		},
+		{
+			name:   "several definitions",
+			source: "Term\n: First.\n: Second.\n",
+			want:   "<dl>\n<dt>Term</dt>\n<dd>First.</dd>\n<dd>Second.</dd>\n</dl>",
+		},
+		{
+			name:       "extension disabled",
+			extensions: []string{"fenced_code"},
+			source:     "Term\n: The definition.\n",
+			want:       "<p>Term\n: The definition.</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				if tt.extensions != nil {
+					c.MarkdownExtensions = tt.extensions
+				}
+			})
+
+			post, err := parsePostBytes("glossary.md", []byte("title: Glossary\n---\n"+tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body := string(post.Body); !strings.Contains(body, tt.want) {
+				t.Errorf("body has no %q: %s", tt.want, body)
+			}
+		})
+	}
+}
+
+func TestParseMarkdownExtensions(t *testing.T) {
+	if _, err := ParseMarkdownExtensions(defaultMarkdownExtensions); err != nil {
+		t.Errorf("the default extensions don't parse: %v", err)
+	}
+	if _, err := ParseMarkdownExtensions([]string{"tables", "definition_list"}); err == nil {
+		t.Error("an unknown extension parsed")
+	}
+}
+
+func TestHeadingOffset(t *testing.T) {
+	source := "# One\n\n## Two\n\n### Three\n\n#### Four\n\n##### Five\n\n###### Six\n"
+
+	tests := []struct {
+		offset int
+		want   []string
+	}{
+		{offset: 0, want: []string{"1", "2", "3", "4", "5", "6"}},
+		{offset: 1, want: []string{"2", "3", "4", "5", "6", "6"}},
+		{offset: 2, want: []string{"3", "4", "5", "6", "6", "6"}},
+		{offset: 5, want: []string{"6", "6", "6", "6", "6", "6"}},
+		{offset: 10, want: []string{"6", "6", "6", "6", "6", "6"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.offset), func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.HeadingOffset = tt.offset })
+
+			var got []string
+			for _, m := range headingPattern.FindAllStringSubmatch(renderBody(t, source), -1) {
+				got = append(got, m[1])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got heading levels %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"html"
+	"io"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// isMermaid reports whether a node is a fenced code block holding a diagram
+func isMermaid(node ast.Node) bool {
+	code, ok := node.(*ast.CodeBlock)
+	return ok && string(code.Info) == "mermaid"
+}
+
+// hasMermaid reports whether a document contains any diagram, so the Mermaid
+// script is only loaded by the posts that need it
+func hasMermaid(doc ast.Node) bool {
+	found := false
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if isMermaid(node) {
+			found = true
+			return ast.Terminate
+		}
+		return ast.GoToNext
+	})
+	return found
+}
+
+// renderMermaidNode is the render hook writing diagrams as they are, for the
+// Mermaid script to pick up
+func renderMermaidNode(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	if !isMermaid(node) {
+		return ast.GoToNext, false
+	}
+
+	io.WriteString(w, "\n<pre class=\"mermaid\">")
+	io.WriteString(w, html.EscapeString(string(node.AsLeaf().Literal)))
+	io.WriteString(w, "</pre>\n")
+	return ast.GoToNext, true
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// metricsPath is where the metrics are served in the Prometheus text format
+const metricsPath = "/metrics"
+
+// durationBuckets are the upper bounds, in seconds, of the request duration
+// histogram. They're the Prometheus client defaults
+var durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// requestMetrics counts the requests served by status and duration
+type requestMetrics struct {
+	mu       sync.Mutex
+	byStatus map[int]uint64
+	buckets  []uint64
+	sum      float64
+	count    uint64
+}
+
+var metrics = &requestMetrics{
+	byStatus: map[int]uint64{},
+	buckets:  make([]uint64, len(durationBuckets)),
+}
+
+// observe records a request
+func (m *requestMetrics) observe(status int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.byStatus[status]++
+	seconds := d.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			m.buckets[i]++
+		}
+	}
+	m.sum += seconds
+	m.count++
+}
+
+// statusRecorder remembers the status code and the size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// Metrics records the status and duration of every request
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		metrics.observe(rec.status, time.Since(start))
+	})
+}
+
+// MetricsHandler serves the request and cache metrics in the Prometheus text
+// format
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	metrics.mu.Lock()
+	statuses := make([]int, 0, len(metrics.byStatus))
+	for status := range metrics.byStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+
+	fmt.Fprintln(w, "# HELP io_http_requests_total Requests served, by status code.")
+	fmt.Fprintln(w, "# TYPE io_http_requests_total counter")
+	for _, status := range statuses {
+		fmt.Fprintf(w, "io_http_requests_total{code=\"%d\"} %d\n", status, metrics.byStatus[status])
+	}
+
+	fmt.Fprintln(w, "# HELP io_http_request_duration_seconds Time spent serving requests.")
+	fmt.Fprintln(w, "# TYPE io_http_request_duration_seconds histogram")
+	for i, bound := range durationBuckets {
+		fmt.Fprintf(w, "io_http_request_duration_seconds_bucket{le=\"%g\"} %d\n", bound, metrics.buckets[i])
+	}
+	fmt.Fprintf(w, "io_http_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", metrics.count)
+	fmt.Fprintf(w, "io_http_request_duration_seconds_sum %g\n", metrics.sum)
+	fmt.Fprintf(w, "io_http_request_duration_seconds_count %d\n", metrics.count)
+	metrics.mu.Unlock()
+
+	counters := []struct {
+		name, help string
+		value      *expvar.Int
+	}{
+		{"io_post_cache_hits_total", "Requests answered with the cached list of posts.", postCacheHits},
+		{"io_post_cache_misses_total", "Times the list of posts had to be loaded again.", postCacheMisses},
+		{"io_render_cache_hits_total", "Posts served from the render cache.", renderCacheHits},
+		{"io_render_cache_misses_total", "Posts that had to be rendered.", renderCacheMisses},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.value.Value())
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	teapot := Metrics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	teapot.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	rec := serve(MetricsHandler, metricsPath, nil)
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("got content type %q", got)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE io_http_requests_total counter",
+		`io_http_requests_total{code="418"} `,
+		"# TYPE io_http_request_duration_seconds histogram",
+		`io_http_request_duration_seconds_bucket{le="+Inf"} `,
+		"io_http_request_duration_seconds_count ",
+		"io_post_cache_hits_total ",
+		"io_post_cache_misses_total ",
+		"io_render_cache_hits_total ",
+		"io_render_cache_misses_total ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("no %q in the metrics:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Trailing slash policies
+const (
+	TrailingSlashStrip = "strip"
+	TrailingSlashAdd   = "add"
+	TrailingSlashOff   = "off"
+)
+
+// trailingSlashPolicy is the policy the server runs with, which the post URLs
+// follow too
+var trailingSlashPolicy = TrailingSlashStrip
+
+// slashable reports whether a path is a page that gets a trailing slash with
+// the "add" policy: not files, and nothing meant for programs
+func slashable(path string) bool {
+	if path == "/" || path == healthPath || path == metricsPath || path == "/stats" {
+		return false
+	}
+	for _, prefix := range []string{"/static/", "/api/", "/admin/", "/debug/"} {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	path = strings.TrimSuffix(path, "/")
+	if strings.HasPrefix(path, "/post/") && strings.HasSuffix(path, "/meta") {
+		return false
+	}
+	return !strings.Contains(path[strings.LastIndex(path, "/")+1:], ".")
+}
+
+// redirectPath cleans up a request path to redirect to. Repeated slashes and
+// dot segments go, and so do slashes and backslashes at the start, which
+// browsers read as //host, a link to another site
+func redirectPath(p string) string {
+	return path.Clean("/" + strings.TrimLeft(p, `/\`))
+}
+
+// TrailingSlash enforces the canonical trailing slash policy. With "strip",
+// safe requests to /foo/ are permanently redirected to /foo (query string
+// included) and any other method is served as if the slash wasn't there.
+// With "add" it's the other way around for pages, /foo redirecting to /foo/,
+// and the routes still see /foo. The root and static files are left alone.
+func TrailingSlash(policy string, next http.Handler) http.Handler {
+	if policy == TrailingSlashAdd {
+		return addTrailingSlash(next)
+	}
+	if policy != TrailingSlashStrip {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if path == "/" || !strings.HasSuffix(path, "/") || strings.HasPrefix(path, "/static/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		canonical := redirectPath(path)
+
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			target := canonical
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = canonical
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// addTrailingSlash is TrailingSlash with the "add" policy
+func addTrailingSlash(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if !slashable(path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !strings.HasSuffix(path, "/") {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				target := redirectPath(path) + "/"
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = strings.TrimRight(path, "/")
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// requestScheme returns the scheme the client used, trusting the proxy in front
+func requestScheme(r *http.Request) string {
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// canonicalHostFor returns the canonical host for a request's host: the
+// configured one, on the port of the request unless it has its own, or the
+// host itself if there's none configured
+func canonicalHostFor(host string) string {
+	if config.CanonicalHost == "" {
+		return host
+	}
+	if _, _, err := net.SplitHostPort(config.CanonicalHost); err == nil {
+		return config.CanonicalHost
+	}
+	if _, port, err := net.SplitHostPort(host); err == nil {
+		return net.JoinHostPort(config.CanonicalHost, port)
+	}
+	return config.CanonicalHost
+}
+
+// CanonicalHost permanently redirects requests for any host other than the
+// configured one to the same URL on the canonical host, and plain HTTP
+// requests to HTTPS if force_https is set. Health checks are never redirected
+func CanonicalHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == healthPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, host := requestScheme(r), r.Host
+		if config.ForceHTTPS {
+			scheme = "https"
+		}
+		if canonical := canonicalHostFor(host); !strings.EqualFold(host, canonical) {
+			host = canonical
+		}
+		if scheme == requestScheme(r) && host == r.Host {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		target := scheme + "://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
+
+// SecurityHeadersConfig sets the security headers of every response. An empty
+// value leaves the header out, and a content_security_policy of "auto" is
+// built from the enabled features
+type SecurityHeadersConfig struct {
+	ContentSecurityPolicy string `yaml:"content_security_policy"`
+	ReferrerPolicy        string `yaml:"referrer_policy"`
+	FrameOptions          string `yaml:"frame_options"`
+}
+
+// nonceKey is the context key of the nonce of a request
+type nonceKey struct{}
+
+// cspNonce returns the nonce inline scripts need to run, "" without a CSP
+func cspNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(nonceKey{}).(string)
+	return nonce
+}
+
+// newNonce returns a random nonce for the CSP
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// withNonce allows the scripts with the nonce in a CSP, adding it to
+// script-src, or to default-src if there's no script-src. A policy with
+// neither doesn't restrict scripts, so it's left alone
+func withNonce(policy string, nonce string) string {
+	directives := strings.Split(policy, ";")
+	for _, name := range []string{"script-src", "default-src"} {
+		for i, directive := range directives {
+			fields := strings.Fields(directive)
+			if len(fields) > 0 && strings.EqualFold(fields[0], name) {
+				directives[i] = strings.TrimRight(directive, " ") + " 'nonce-" + nonce + "'"
+				return strings.Join(directives, ";")
+			}
+		}
+	}
+	return policy
+}
+
+// SecurityHeaders adds the configured security headers to every response,
+// along with X-Content-Type-Options since every response has its type set.
+// Each request gets a new nonce in the CSP for the inline scripts
+func SecurityHeaders(next http.Handler) http.Handler {
+	c := config.SecurityHeaders
+	policy := c.ContentSecurityPolicy
+	if policy == CSPAuto {
+		policy = buildContentSecurityPolicy(config)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if policy != "" {
+			nonce, err := newNonce()
+			if err != nil {
+				log.Printf("Error generating nonce: %v", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), nonceKey{}, nonce))
+			w.Header().Set("Content-Security-Policy", withNonce(policy, nonce))
+		}
+		if c.ReferrerPolicy != "" {
+			w.Header().Set("Referrer-Policy", c.ReferrerPolicy)
+		}
+		if c.FrameOptions != "" {
+			w.Header().Set("X-Frame-Options", c.FrameOptions)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
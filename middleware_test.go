@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/xml"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	cspNoncePattern    = regexp.MustCompile(`'nonce-([^']+)'`)
+	scriptNoncePattern = regexp.MustCompile(`<script[^>]* nonce="([^"]+)"`)
+)
+
+// okHandler answers every request with 200 OK
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+func TestCanonicalHost(t *testing.T) {
+	tests := []struct {
+		name       string
+		canonical  string
+		forceHTTPS bool
+		url        string
+		proto      string
+		want       string
+	}{
+		{name: "not configured", url: "http://www.example.com/post/a", want: ""},
+		{name: "canonical host", canonical: "example.com", url: "http://example.com/post/a", want: ""},
+		{name: "canonical host in another case", canonical: "example.com", url: "http://Example.COM/post/a", want: ""},
+		{name: "www", canonical: "example.com", url: "http://www.example.com/post/a?x=1", want: "http://example.com/post/a?x=1"},
+		{name: "apex", canonical: "www.example.com", url: "http://example.com/", want: "http://www.example.com/"},
+		{name: "https behind a proxy", canonical: "example.com", url: "http://www.example.com/tag/go", proto: "https", want: "https://example.com/tag/go"},
+		{name: "port of the request", canonical: "example.com", url: "http://www.example.com:8081/post/a", want: "http://example.com:8081/post/a"},
+		{name: "port of the canonical host", canonical: "example.com:8443", url: "http://www.example.com:8081/post/a", want: "http://example.com:8443/post/a"},
+		{name: "health check", canonical: "example.com", url: "http://10.0.0.1/healthz", want: ""},
+		{name: "force https", forceHTTPS: true, url: "http://example.com/post/a", want: "https://example.com/post/a"},
+		{name: "force https already https", forceHTTPS: true, url: "http://example.com/post/a", proto: "https", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.CanonicalHost = tt.canonical
+				c.ForceHTTPS = tt.forceHTTPS
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			rec := httptest.NewRecorder()
+			CanonicalHost(okHandler).ServeHTTP(rec, req)
+
+			if tt.want == "" {
+				if rec.Code != http.StatusOK {
+					t.Errorf("got status %d, redirected to %q", rec.Code, rec.Header().Get("Location"))
+				}
+				return
+			}
+			if rec.Code != http.StatusMovedPermanently {
+				t.Fatalf("got status %d, want %d", rec.Code, http.StatusMovedPermanently)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("redirected to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	tests := []struct {
+		policy   string
+		method   string
+		path     string
+		redirect string
+		served   string
+	}{
+		{policy: TrailingSlashStrip, method: http.MethodGet, path: "/post/a/", redirect: "/post/a"},
+		{policy: TrailingSlashStrip, method: http.MethodGet, path: "/post/a/?x=1", redirect: "/post/a?x=1"},
+		{policy: TrailingSlashStrip, method: http.MethodGet, path: "/post/a", served: "/post/a"},
+		{policy: TrailingSlashStrip, method: http.MethodPost, path: "/preview/", served: "/preview"},
+		{policy: TrailingSlashStrip, method: http.MethodGet, path: "/", served: "/"},
+		{policy: TrailingSlashStrip, method: http.MethodGet, path: "/static/img/", served: "/static/img/"},
+		{policy: TrailingSlashStrip, method: http.MethodGet, path: "//evil.com/", redirect: "/evil.com"},
+		{policy: TrailingSlashStrip, method: http.MethodGet, path: "///evil.com//", redirect: "/evil.com"},
+		{policy: TrailingSlashStrip, method: http.MethodGet, path: "/\\evil.com/", redirect: "/evil.com"},
+		{policy: TrailingSlashStrip, method: http.MethodGet, path: "/post/../tag/go/", redirect: "/tag/go"},
+		{policy: TrailingSlashStrip, method: http.MethodPost, path: "//evil.com/", served: "/evil.com"},
+		{policy: TrailingSlashAdd, method: http.MethodGet, path: "/post/a", redirect: "/post/a/"},
+		{policy: TrailingSlashAdd, method: http.MethodHead, path: "/tag/go?x=1", redirect: "/tag/go/?x=1"},
+		{policy: TrailingSlashAdd, method: http.MethodGet, path: "/post/a/", served: "/post/a"},
+		{policy: TrailingSlashAdd, method: http.MethodPost, path: "/preview", served: "/preview"},
+		{policy: TrailingSlashAdd, method: http.MethodGet, path: "/feed.xml", served: "/feed.xml"},
+		{policy: TrailingSlashAdd, method: http.MethodGet, path: "/api/posts", served: "/api/posts"},
+		{policy: TrailingSlashAdd, method: http.MethodGet, path: "/post/a/meta", served: "/post/a/meta"},
+		{policy: TrailingSlashAdd, method: http.MethodGet, path: "/", served: "/"},
+		{policy: TrailingSlashAdd, method: http.MethodGet, path: "//evil.com/x", redirect: "/evil.com/x/"},
+		{policy: TrailingSlashAdd, method: http.MethodGet, path: "//localhost", redirect: "/localhost/"},
+		{policy: TrailingSlashAdd, method: http.MethodGet, path: "/\\localhost", redirect: "/localhost/"},
+		{policy: TrailingSlashAdd, method: http.MethodGet, path: "/post/../tag/go", redirect: "/tag/go/"},
+		{policy: TrailingSlashOff, method: http.MethodGet, path: "/post/a/", served: "/post/a/"},
+		{policy: TrailingSlashOff, method: http.MethodGet, path: "/post/a", served: "/post/a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy+" "+tt.method+" "+tt.path, func(t *testing.T) {
+			served := ""
+			handler := TrailingSlash(tt.policy, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served = r.URL.Path
+			}))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if tt.redirect != "" {
+				if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != tt.redirect {
+					t.Errorf("got status %d to %q, want a redirect to %q", rec.Code, rec.Header().Get("Location"), tt.redirect)
+				}
+				return
+			}
+			if served != tt.served {
+				t.Errorf("served %q, want %q", served, tt.served)
+			}
+		})
+	}
+}
+
+func TestTrailingSlashCanonical(t *testing.T) {
+	tests := []struct {
+		policy string
+		path   string
+		want   string
+	}{
+		{policy: TrailingSlashStrip, path: "/post/hello", want: "http://example.com/post/hello"},
+		{policy: TrailingSlashAdd, path: "/post/hello/", want: "http://example.com/post/hello/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			withPosts(t, map[string]string{
+				"hello.md": "title: Hello\ndate: 2024-01-01T00:00:00Z\n---\nHello\n",
+			}, nil)
+			withTemplates(t)
+			saved := trailingSlashPolicy
+			t.Cleanup(func() { trailingSlashPolicy = saved })
+			trailingSlashPolicy = tt.policy
+
+			public, _ := routes(false, false, false)
+			rec := httptest.NewRecorder()
+			TrailingSlash(tt.policy, public).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, redirected to %q", rec.Code, rec.Header().Get("Location"))
+			}
+			m := canonicalPattern.FindStringSubmatch(rec.Body.String())
+			if m == nil || m[1] != tt.want {
+				t.Errorf("got canonical %v, want %q", m, tt.want)
+			}
+
+			var sitemap URLSet
+			if err := xml.Unmarshal(serve(SitemapHandler, "/sitemap.xml", nil).Body.Bytes(), &sitemap); err != nil {
+				t.Fatal(err)
+			}
+			if got := sitemap.URLs[1].Loc; got != tt.want {
+				t.Errorf("got %q in the sitemap, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers SecurityHeadersConfig
+		want    map[string]string
+	}{
+		{
+			name:    "defaults",
+			headers: DefaultConfig().SecurityHeaders,
+			want: map[string]string{
+				"X-Content-Type-Options": "nosniff",
+				"Referrer-Policy":        "strict-origin-when-cross-origin",
+				"X-Frame-Options":        "DENY",
+			},
+		},
+		{
+			name: "configured",
+			headers: SecurityHeadersConfig{
+				ContentSecurityPolicy: "default-src 'self'",
+				ReferrerPolicy:        "no-referrer",
+				FrameOptions:          "SAMEORIGIN",
+			},
+			want: map[string]string{
+				"X-Content-Type-Options": "nosniff",
+				"Referrer-Policy":        "no-referrer",
+				"X-Frame-Options":        "SAMEORIGIN",
+			},
+		},
+		{
+			name:    "disabled",
+			headers: SecurityHeadersConfig{},
+			want: map[string]string{
+				"X-Content-Type-Options":  "nosniff",
+				"Referrer-Policy":         "",
+				"X-Frame-Options":         "",
+				"Content-Security-Policy": "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{}, func(c *Config) { c.SecurityHeaders = tt.headers })
+			withTemplates(t)
+
+			rec := httptest.NewRecorder()
+			SecurityHeaders(http.HandlerFunc(IndexHandler)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if got := rec.Header().Get("Content-Type"); got != contentTypeHTML {
+				t.Fatalf("got content type %q", got)
+			}
+			for name, want := range tt.want {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("got %s %q, want %q", name, got, want)
+				}
+			}
+			if csp := rec.Header().Get("Content-Security-Policy"); tt.headers.ContentSecurityPolicy != "" && !strings.Contains(csp, "default-src 'self'") {
+				t.Errorf("got Content-Security-Policy %q", csp)
+			}
+		})
+	}
+}
+
+func TestNonce(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   string
+	}{
+		{name: "script-src", policy: "default-src 'self'; script-src 'self'", want: "default-src 'self'; script-src 'self' 'nonce-abc'"},
+		{name: "default-src only", policy: "default-src 'self'; img-src *", want: "default-src 'self' 'nonce-abc'; img-src *"},
+		{name: "neither", policy: "img-src *", want: "img-src *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withNonce(tt.policy, "abc"); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNonceInScripts(t *testing.T) {
+	withPosts(t, map[string]string{
+		"chart.md": "title: Chart\ndate: 2024-01-01T00:00:00Z\n---\n```mermaid\ngraph TD; A-->B;\n```\n",
+	}, nil)
+	withTemplates(t)
+
+	handler := SecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		post, err := GetPost("chart")
+		if err != nil {
+			t.Fatal(err)
+		}
+		renderPost(w, r, post)
+	}))
+
+	var nonces []string
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/post/chart", nil))
+
+		header := cspNoncePattern.FindStringSubmatch(rec.Header().Get("Content-Security-Policy"))
+		script := scriptNoncePattern.FindStringSubmatch(rec.Body.String())
+		if header == nil || script == nil {
+			t.Fatalf("no nonce in the header or in the script: %q, %q", header, script)
+		}
+		// Browsers read the attribute unescaped, + and all
+		if nonce := html.UnescapeString(script[1]); header[1] != nonce {
+			t.Errorf("the header has nonce %q, the script %q", header[1], nonce)
+		}
+		nonces = append(nonces, header[1])
+	}
+	if nonces[0] == nonces[1] {
+		t.Error("two requests got the same nonce")
+	}
+}
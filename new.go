@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// slugify turns a title into something fit for a filename and a URL
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// newPostTemplate is the front matter of a new post
+const newPostTemplate = `---
+title: %q
+date: %q
+tags:
+draft: true
+---
+
+`
+
+// RunNew creates a draft post with the given title in the first content
+// directory, prints its path and returns the exit code
+func RunNew(w io.Writer, args []string) int {
+	if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+		fmt.Fprintln(w, `usage: io new "Post title"`)
+		return 2
+	}
+	title := strings.TrimSpace(args[0])
+
+	slug := slugify(title)
+	if slug == "" {
+		fmt.Fprintf(w, "can't make a filename out of %q\n", title)
+		return 1
+	}
+	file := filepath.Join(config.ContentDirs[0], slug+config.PostExtensions[0])
+
+	if existing, err := findPostFile(slug); err == nil {
+		fmt.Fprintf(w, "%s already exists\n", existing)
+		return 1
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		fmt.Fprintf(w, "can't create %s: %v\n", file, err)
+		return 1
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, newPostTemplate, title, time.Now().Format(time.RFC3339)); err != nil {
+		fmt.Fprintf(w, "can't write %s: %v\n", file, err)
+		return 1
+	}
+
+	fmt.Fprintln(w, file)
+	return 0
+}
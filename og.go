@@ -0,0 +1,242 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	ogWidth    = 1200
+	ogHeight   = 630
+	ogMargin   = 80
+	ogMaxLines = 4
+)
+
+var (
+	ogBackground = "static/img/og-background.png"
+	ogCacheDir   = "cache/og"
+
+	ogBackgroundColor = color.RGBA{0xF0, 0xE1, 0xCE, 0xFF}
+	ogTextColor       = color.RGBA{0x13, 0x02, 0x05, 0xFF}
+)
+
+// ogImageURL returns the path of the share image generated for a post, next
+// to the post itself
+func ogImageURL(post Post) string {
+	return strings.TrimSuffix(postURL(post), "/") + "/og.png"
+}
+
+// OGImageHandler serves a share image for a post, rendering it on first use.
+// It's at ogImageURL, and at /post/<slug>/og.png for every post
+func OGImageHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	title, ok := vars["title"]
+	if !ok {
+		title = vars["slug"]
+	}
+
+	post, err := GetPost(title)
+	if err == nil && !ok && ogImageURL(post) != r.URL.Path {
+		err = os.ErrNotExist
+	}
+	if os.IsNotExist(err) || (err == nil && !post.IsVisible(time.Now())) {
+		log.Printf("Post not found: %s", title)
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error getting post: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	cached := ogCachePath(post)
+	if _, err := os.Stat(cached); os.IsNotExist(err) {
+		if err := renderOGImage(cached, post); err != nil {
+			log.Printf("Error rendering OG image for %s: %v", title, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if err := pruneOGImages(post, cached); err != nil {
+			log.Printf("Error removing old OG images for %s: %v", title, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeFile(w, r, cached)
+}
+
+// ogCacheKey names the cached share image of a post. It changes with the
+// post and with the date as drawn, which depends on date_format and timezone
+func ogCacheKey(post Post) string {
+	return contentHash([]byte(post.ContentHash + "\n" + PostDate(post.Date)))
+}
+
+// ogSlugKey is the part of the names of the cached share images of a post
+// that stays the same across versions. It's a hash so that one slug can't be
+// the start of another's, or anything but a plain file name
+func ogSlugKey(post Post) string {
+	return contentHash([]byte(post.Slug()))[:16]
+}
+
+// ogCachePath returns where the share image of the post as it is now is cached
+func ogCachePath(post Post) string {
+	return filepath.Join(ogCacheDir, ogSlugKey(post)+"-"+ogCacheKey(post)+".png")
+}
+
+// pruneOGImages removes the cached share images of older versions of a post,
+// all but keep, so the cache holds one per post
+func pruneOGImages(post Post, keep string) error {
+	files, err := filepath.Glob(filepath.Join(ogCacheDir, ogSlugKey(post)+"-*.png"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file == keep {
+			continue
+		}
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderOGImage draws the title and date of a post and writes the PNG to path
+func renderOGImage(path string, post Post) error {
+	img := image.NewRGBA(image.Rect(0, 0, ogWidth, ogHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(ogBackgroundColor), image.Point{}, draw.Src)
+
+	if f, err := os.Open(ogBackground); err == nil {
+		bg, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		draw.CatmullRom.Scale(img, img.Bounds(), bg, bg.Bounds(), draw.Src, nil)
+	}
+
+	ttf, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return err
+	}
+	titleFace, err := opentype.NewFace(ttf, &opentype.FaceOptions{Size: 64, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return err
+	}
+	defer titleFace.Close()
+	dateFace, err := opentype.NewFace(ttf, &opentype.FaceOptions{Size: 32, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return err
+	}
+	defer dateFace.Close()
+
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(ogTextColor), Face: titleFace}
+	lineHeight := titleFace.Metrics().Height.Ceil()
+	y := ogMargin + titleFace.Metrics().Ascent.Ceil()
+	for _, line := range wrapText(d, post.Title, ogWidth-2*ogMargin, ogMaxLines) {
+		d.Dot = fixed.P(ogMargin, y)
+		d.DrawString(line)
+		y += lineHeight
+	}
+
+	d.Face = dateFace
+	d.Dot = fixed.P(ogMargin, ogHeight-ogMargin)
+	d.DrawString(PostDate(post.Date))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so concurrent requests never serve half an image
+	tmp, err := os.CreateTemp(filepath.Dir(path), "og-*.png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := png.Encode(tmp, img); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// wrapText splits text into lines no wider than width, truncating with an
+// ellipsis after maxLines. Words too long for a line of their own are broken
+// wherever they have to be
+func wrapText(d *font.Drawer, text string, width int, maxLines int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if d.MeasureString(candidate).Ceil() <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		pieces := breakWord(d, word, width)
+		lines = append(lines, pieces[:len(pieces)-1]...)
+		line = pieces[len(pieces)-1]
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		last := lines[maxLines-1]
+		for last != "" && d.MeasureString(last+"…").Ceil() > width {
+			if i := strings.LastIndex(last, " "); i >= 0 {
+				last = last[:i]
+			} else {
+				runes := []rune(last)
+				last = string(runes[:len(runes)-1])
+			}
+		}
+		lines[maxLines-1] = last + "…"
+	}
+
+	return lines
+}
+
+// breakWord splits a word into pieces no wider than width, or a single
+// character if even that is too wide
+func breakWord(d *font.Drawer, word string, width int) []string {
+	var pieces []string
+	piece := ""
+	for _, r := range word {
+		if piece != "" && d.MeasureString(piece+string(r)).Ceil() > width {
+			pieces = append(pieces, piece)
+			piece = ""
+		}
+		piece += string(r)
+	}
+	return append(pieces, piece)
+}
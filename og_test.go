@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// withOGCache runs a test with an empty share image cache
+func withOGCache(t *testing.T) string {
+	t.Helper()
+
+	saved := ogCacheDir
+	t.Cleanup(func() { ogCacheDir = saved })
+	ogCacheDir = t.TempDir()
+	return ogCacheDir
+}
+
+// cachedOGImages lists the file names in the share image cache
+func cachedOGImages(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestOGImageCache(t *testing.T) {
+	dir := withOGCache(t)
+	posts := writeFiles(t, map[string]string{
+		"hello.md":       "title: Hello\ndate: 2024-01-01T00:00:00Z\n---\nHello\n",
+		"hello-there.md": "title: Hello there\ndate: 2024-01-02T00:00:00Z\n---\nHi\n",
+	})
+	withConfig(t, func(c *Config) { c.ContentDirs = []string{posts} })
+
+	get := func(slug string) string {
+		t.Helper()
+		rec := serve(OGImageHandler, "/post/"+slug+"/og.png", map[string]string{"slug": slug})
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("got status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		post, err := GetPost(slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return filepath.Base(ogCachePath(post))
+	}
+
+	hello, there := get("hello"), get("hello-there")
+	if got := cachedOGImages(t, dir); len(got) != 2 {
+		t.Fatalf("got cached images %v, want one per post", got)
+	}
+
+	tests := []struct {
+		name  string
+		title string
+	}{
+		{name: "new title", title: "Hello, again"},
+		{name: "another title", title: "Goodbye"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "title: " + tt.title + "\ndate: 2024-01-01T00:00:00Z\n---\nHello\n"
+			if err := os.WriteFile(filepath.Join(posts, "hello.md"), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			edited := get("hello")
+			if edited == hello {
+				t.Fatal("the edited post kept its image")
+			}
+			hello = edited
+
+			// The old image of the edited post goes, the other post's stays
+			want := []string{hello, there}
+			sort.Strings(want)
+			if got := cachedOGImages(t, dir); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+				t.Errorf("got cached images %v, want %v", got, want)
+			}
+		})
+	}
+}
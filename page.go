@@ -0,0 +1,103 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PageData is what the templates get for every page. newPageData fills in
+// what all pages have in common, the handlers the fields for their own page
+type PageData struct {
+	Site        Config
+	BaseURL     string
+	Canonical   string
+	IsHome      bool
+	Lang        string
+	Description string
+	Draft       bool
+	Analytics   *AnalyticsConfig
+	Nonce       string
+	Recent      []Post
+
+	// The Open Graph and Twitter card of the page. OGImage is absolute, and
+	// empty if there's no image for the page
+	SiteName string
+	OGType   string
+	OGTitle  string
+	OGImage  string
+	Twitter  string
+
+	// The index, and the pages of tags
+	IntroHTML    template.HTML
+	Tag          string
+	Posts        []Post
+	Updated      []Post
+	Empty        bool
+	EmptyMessage string
+
+	// Posts, pages and notes
+	Post         Post
+	Watermark    string
+	Comments     CommentsConfig
+	Translations []Post
+	Related      []Post
+	Backlinks    []Post
+	Prev         *Post
+	Next         *Post
+	Alternates   []Alternate
+	MermaidURL   string
+
+	// The not found page
+	Suggestions []Post
+
+	// The list of drafts
+	Drafts []Post
+}
+
+// baseURL returns the scheme and host the request was made to
+func baseURL(r *http.Request) string {
+	return requestScheme(r) + "://" + r.Host
+}
+
+// newPageData returns the data every page has for a request. draft tells
+// whether the page shows drafts, which are kept out of analytics, and posts
+// are all the posts, as loaded once by the handler
+func newPageData(r *http.Request, draft bool, posts []Post) PageData {
+	base := baseURL(r)
+	return PageData{
+		Site:        config,
+		BaseURL:     base,
+		Canonical:   base + r.URL.Path,
+		Lang:        config.Language,
+		Description: config.Description,
+		Draft:       draft,
+		Analytics:   analyticsFor(draft),
+		Nonce:       cspNonce(r),
+		Recent:      RecentPosts(posts, config.RecentPosts, time.Now()),
+		SiteName:    siteName(),
+		OGType:      "website",
+		OGTitle:     config.Title,
+		OGImage:     absoluteURL(base, cdnURL(config.OGImage)),
+		Twitter:     config.Twitter,
+	}
+}
+
+// siteName returns the name of the site in social previews, site_name or else
+// the title
+func siteName() string {
+	if config.SiteName != "" {
+		return config.SiteName
+	}
+	return config.Title
+}
+
+// absoluteURL returns link as an absolute URL, resolving paths against base,
+// the scheme and host. Empty links stay empty
+func absoluteURL(base string, link string) string {
+	if link == "" || strings.Contains(link, "://") {
+		return link
+	}
+	return base + link
+}
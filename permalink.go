@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// permalinkTokens are what a permalink pattern can have in its segments, and
+// the route variables they turn into
+var permalinkTokens = map[string]string{
+	":year":  "{year:[0-9]{4}}",
+	":month": "{month:[0-9]{2}}",
+	":day":   "{day:[0-9]{2}}",
+	":slug":  "{slug}",
+}
+
+// validatePermalink checks a permalink pattern like /:year/:month/:slug. Every
+// segment is either a token or plain text, and there's at least one other
+// than the slug, or posts would take the place of pages
+func validatePermalink(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("permalink %q has to start with /", pattern)
+	}
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+	slugs := 0
+	for _, segment := range segments {
+		if segment == ":slug" {
+			slugs++
+		} else if _, ok := permalinkTokens[segment]; !ok && (strings.Contains(segment, ":") || segment == "") {
+			return fmt.Errorf("permalink %q has an unknown segment %q, expected :year, :month, :day, :slug or plain text", pattern, segment)
+		}
+	}
+	if slugs != 1 {
+		return fmt.Errorf("permalink %q needs exactly one :slug", pattern)
+	}
+	if len(segments) < 2 {
+		return fmt.Errorf("permalink %q needs something besides :slug", pattern)
+	}
+	return nil
+}
+
+// permalinkRoute turns the permalink pattern into a route
+func permalinkRoute(pattern string) string {
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+	for i, segment := range segments {
+		if route, ok := permalinkTokens[segment]; ok {
+			segments[i] = route
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// expandPermalink returns the path of a post following the permalink pattern,
+// false if there's no pattern or the post has no valid date to put in it
+func expandPermalink(pattern string, post Post) (string, bool) {
+	if pattern == "" {
+		return "", false
+	}
+	date, err := time.Parse(time.RFC3339, post.Date)
+	if err != nil {
+		return "", false
+	}
+	date = inSiteZone(date)
+	return strings.NewReplacer(
+		":year", date.Format("2006"),
+		":month", date.Format("01"),
+		":day", date.Format("02"),
+		":slug", post.Slug(),
+	).Replace(pattern), true
+}
+
+// PermalinkHandler serves posts at the configured permalink. The date in the
+// URL has to be the date of the post, anything else is not found
+func PermalinkHandler(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	post, err := GetPost(slug)
+	if os.IsNotExist(err) || (err == nil && (!post.IsVisible(time.Now()) || post.Type == TypePage)) {
+		log.Printf("Post not found: %s", slug)
+		postNotFound(w, r, slug)
+		return
+	} else if err != nil {
+		log.Printf("Error getting post: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if strings.TrimSuffix(postURL(post), "/") != r.URL.Path {
+		log.Printf("Post %s isn't at %s", slug, r.URL.Path)
+		postNotFound(w, r, slug)
+		return
+	}
+
+	renderPost(w, r, post)
+}
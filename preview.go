@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// previewFilename names the posts rendered by the preview
+const previewFilename = "preview.md"
+
+// PreviewHandler renders the Markdown and front matter POSTed to it as a post
+// page, through the same pipeline as the files in posts/. Previews are always
+// drafts, and the route only exists in dev mode
+func PreviewHandler(w http.ResponseWriter, r *http.Request) {
+	content, err := readPost(r.Body)
+	if errors.Is(err, errPostTooLarge) {
+		http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		log.Printf("Error reading preview: %v", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	post, err := parsePostBytes(previewFilename, content)
+	if err != nil {
+		// Only ever served in dev mode, so the details are fine to show
+		http.Error(w, "Can't parse the post: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	post.Draft = true
+	post.ModTime = time.Now()
+
+	renderPost(w, r, post)
+}
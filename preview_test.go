@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPreviewHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		maxSize int64
+		status  int
+		want    string
+	}{
+		{
+			name:   "post",
+			body:   "title: Preview\n---\n# Heading\n\nSome **bold** text.\n",
+			status: http.StatusOK,
+			want:   "<p>Some <strong>bold</strong> text.</p>",
+		},
+		{name: "no front matter", body: "Just text", status: http.StatusBadRequest, want: "invalid front matter"},
+		{name: "too large", body: "title: Big\n---\n" + strings.Repeat("x", 100), maxSize: 50, status: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{}, func(c *Config) {
+				if tt.maxSize > 0 {
+					c.MaxPostSize = tt.maxSize
+				}
+			})
+			withTemplates(t)
+
+			rec := httptest.NewRecorder()
+			PreviewHandler(rec, httptest.NewRequest(http.MethodPost, "/preview", strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.status)
+			}
+			if body := rec.Body.String(); !strings.Contains(body, tt.want) {
+				t.Errorf("response has no %q: %s", tt.want, body)
+			}
+		})
+	}
+}
+
+func TestPreviewOnlyInDevMode(t *testing.T) {
+	for _, dev := range []bool{true, false} {
+		t.Run(fmt.Sprintf("dev=%v", dev), func(t *testing.T) {
+			withPosts(t, map[string]string{}, nil)
+			withTemplates(t)
+			saved := devMode
+			t.Cleanup(func() { devMode = saved })
+			devMode = dev
+
+			public, _ := routes(false, false, false)
+			rec := httptest.NewRecorder()
+			public.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/preview", strings.NewReader("title: Preview\n---\nText\n")))
+			if got := rec.Code == http.StatusOK; got != dev {
+				t.Errorf("got status %d", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SearchEntry is a post as seen by the client-side search
+type SearchEntry struct {
+	Slug  string   `json:"slug"`
+	URL   string   `json:"url"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+	Date  string   `json:"date"`
+	Body  string   `json:"body"`
+}
+
+var (
+	tagPattern        = regexp.MustCompile(`<\/?([a-zA-Z][a-zA-Z0-9]*)?[^>]*>`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// blockTags are the elements that separate words, unlike inline ones like
+// <a> or <em> that can sit right next to punctuation
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "br": true,
+	"dd": true, "div": true, "dl": true, "dt": true, "figcaption": true,
+	"figure": true, "footer": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"li": true, "nav": true, "ol": true, "p": true, "pre": true,
+	"section": true, "table": true, "td": true, "th": true, "tr": true,
+	"ul": true,
+}
+
+// StripHTML turns rendered HTML into plain text. Block elements become
+// spaces, inline ones disappear
+func StripHTML(s string) string {
+	s = tagPattern.ReplaceAllStringFunc(s, func(tag string) string {
+		if blockTags[strings.ToLower(tagPattern.FindStringSubmatch(tag)[1])] {
+			return " "
+		}
+		return ""
+	})
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(s, " "))
+}
+
+// truncateWords cuts s to about n characters at a word boundary, with an
+// ellipsis if anything was cut. n <= 0 means no limit
+func truncateWords(s string, n int) string {
+	runes := []rune(s)
+	if n <= 0 || len(runes) <= n {
+		return s
+	}
+	cut := string(runes[:n])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:-") + "…"
+}
+
+// Excerpt returns the first words of some text, rendered HTML or plain, as
+// plain text with an ellipsis if anything was cut. The templates escape it
+func Excerpt(text interface{}, words int) string {
+	var s string
+	switch v := text.(type) {
+	case template.HTML:
+		s = StripHTML(string(v))
+	case string:
+		s = v
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	fields := strings.Fields(s)
+	if words <= 0 || len(fields) <= words {
+		return strings.Join(fields, " ")
+	}
+	return strings.TrimRight(strings.Join(fields[:words], " "), ",.;:-") + "…"
+}
+
+// searchIndexValidUntil returns when the index built at now has to be rebuilt
+// because one of the posts in it expires
+func searchIndexValidUntil(posts []Post, now time.Time) time.Time {
+	validUntil := now.Add(24 * time.Hour)
+	for _, post := range posts {
+		if post.Expires == "" {
+			continue
+		}
+		if expires, err := time.Parse(time.RFC3339, post.Expires); err == nil && expires.After(now) && expires.Before(validUntil) {
+			validUntil = expires
+		}
+	}
+	return validUntil
+}
+
+// SearchIndexHandler serves all the published posts as a compact JSON document
+func SearchIndexHandler(w http.ResponseWriter, r *http.Request) {
+	posts, stamp, err := getAllPosts()
+	if err != nil {
+		log.Printf("Error getting all posts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	index, ok := postCache.SearchIndex(stamp, now)
+	if !ok {
+		published := ListedPosts(posts, now)
+		entries := make([]SearchEntry, 0, len(published))
+		for _, post := range published {
+			entries = append(entries, SearchEntry{
+				Slug:  post.Slug(),
+				URL:   postURL(post),
+				Title: post.Title,
+				Tags:  post.TagList(),
+				Date:  post.Date,
+				Body:  StripHTML(string(post.Body)),
+			})
+		}
+
+		index, err = json.Marshal(entries)
+		if err != nil {
+			log.Printf("Error encoding search index: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		postCache.SetSearchIndex(stamp, index, searchIndexValidUntil(posts, now))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(index)
+}
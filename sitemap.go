@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// URLSet is a sitemap
+type URLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []SitemapURL `xml:"url"`
+}
+
+// SitemapURL is a page in a sitemap
+type SitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+// SitemapConfig sets what goes in the sitemap besides the home page and the
+// posts, and how often each kind of page changes and how much it matters
+type SitemapConfig struct {
+	Tags bool `yaml:"tags"`
+
+	Home      SitemapEntryConfig `yaml:"home"`
+	Posts     SitemapEntryConfig `yaml:"posts"`
+	Pages     SitemapEntryConfig `yaml:"pages"`
+	TagsEntry SitemapEntryConfig `yaml:"tag_pages"`
+}
+
+// SitemapEntryConfig is the changefreq and priority of a kind of page. Empty
+// and zero leave them out
+type SitemapEntryConfig struct {
+	ChangeFreq string  `yaml:"changefreq"`
+	Priority   float64 `yaml:"priority"`
+}
+
+// sitemapChangeFreqs are the changefreq values sitemaps know about
+var sitemapChangeFreqs = map[string]bool{
+	"": true, "always": true, "hourly": true, "daily": true, "weekly": true, "monthly": true, "yearly": true, "never": true,
+}
+
+// validate checks the changefreq and priority of every kind of page
+func (c SitemapConfig) validate() error {
+	for name, entry := range map[string]SitemapEntryConfig{"home": c.Home, "posts": c.Posts, "pages": c.Pages, "tag_pages": c.TagsEntry} {
+		if !sitemapChangeFreqs[entry.ChangeFreq] {
+			return fmt.Errorf("invalid sitemap.%s.changefreq: %s", name, entry.ChangeFreq)
+		}
+		if entry.Priority < 0 || entry.Priority > 1 {
+			return fmt.Errorf("sitemap.%s.priority has to be between 0 and 1", name)
+		}
+	}
+	return nil
+}
+
+// url returns a sitemap URL for a page of this kind
+func (e SitemapEntryConfig) url(loc string, lastMod string) SitemapURL {
+	u := SitemapURL{Loc: loc, LastMod: lastMod, ChangeFreq: e.ChangeFreq}
+	if e.Priority > 0 {
+		u.Priority = strconv.FormatFloat(e.Priority, 'f', 1, 64)
+	}
+	return u
+}
+
+// SitemapIndex lists the sitemaps of a site too large for a single one
+type SitemapIndex struct {
+	XMLName  xml.Name         `xml:"sitemapindex"`
+	XMLNS    string           `xml:"xmlns,attr"`
+	Sitemaps []SitemapPointer `xml:"sitemap"`
+}
+
+// SitemapPointer is a sitemap in a sitemap index
+type SitemapPointer struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapURLs lists the home page, every published post and page and, if
+// configured, the pages of the tags. base is the scheme and host the URLs
+// start with
+func sitemapURLs(posts []Post, base string) []SitemapURL {
+	c := config.Sitemap
+	urls := []SitemapURL{c.Home.url(base+"/", "")}
+	for _, post := range posts {
+		lastMod := post.Date
+		if post.WasUpdated {
+			lastMod = post.LastUpdated().Format(time.RFC3339)
+		}
+		entry := c.Posts
+		if post.Type == TypePage {
+			entry = c.Pages
+		}
+		urls = append(urls, entry.url(base+postURL(post), lastMod))
+	}
+
+	if c.Tags {
+		listed := ListedPosts(posts, time.Now())
+		for _, tag := range allTags(listed) {
+			// Posts are newest first, so the first one is the latest
+			latest := taggedPosts(listed, tag)[0]
+			urls = append(urls, c.TagsEntry.url(base+tagURL(tag), latest.Date))
+		}
+	}
+	return urls
+}
+
+// sitemapPages returns how many sitemaps n URLs need
+func sitemapPages(n int) int {
+	if config.SitemapSize <= 0 {
+		return 1
+	}
+	return (n + config.SitemapSize - 1) / config.SitemapSize
+}
+
+// writeSitemap encodes a sitemap or a sitemap index as the response
+func writeSitemap(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding sitemap: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// SitemapHandler serves the sitemap, or an index of sitemaps once there are
+// more URLs than fit in one
+func SitemapHandler(w http.ResponseWriter, r *http.Request) {
+	posts, err := GetAllPosts()
+	if err != nil {
+		log.Printf("Error getting all posts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	urls := sitemapURLs(PublishedPosts(posts, time.Now()), baseURL(r))
+	pages := sitemapPages(len(urls))
+	if pages <= 1 {
+		writeSitemap(w, URLSet{XMLNS: sitemapNamespace, URLs: urls})
+		return
+	}
+
+	index := SitemapIndex{XMLNS: sitemapNamespace}
+	for page := 1; page <= pages; page++ {
+		index.Sitemaps = append(index.Sitemaps, SitemapPointer{
+			Loc: fmt.Sprintf("%s/sitemap-%d.xml", baseURL(r), page),
+		})
+	}
+	writeSitemap(w, index)
+}
+
+// SitemapPageHandler serves one of the sitemaps listed in the sitemap index.
+// They only exist when there's an index
+func SitemapPageHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(mux.Vars(r)["page"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	posts, err := GetAllPosts()
+	if err != nil {
+		log.Printf("Error getting all posts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	urls := sitemapURLs(PublishedPosts(posts, time.Now()), baseURL(r))
+	pages := sitemapPages(len(urls))
+	if pages <= 1 || page < 1 || page > pages {
+		http.NotFound(w, r)
+		return
+	}
+
+	start := (page - 1) * config.SitemapSize
+	end := start + config.SitemapSize
+	if end > len(urls) {
+		end = len(urls)
+	}
+	writeSitemap(w, URLSet{XMLNS: sitemapNamespace, URLs: urls[start:end]})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sitemapPosts returns n posts for the sitemap tests
+func sitemapPosts(n int) map[string]string {
+	posts := map[string]string{}
+	for i := 1; i <= n; i++ {
+		posts[fmt.Sprintf("post-%d.md", i)] = fmt.Sprintf("title: Post %d\ndate: 2024-01-%02dT00:00:00Z\ntags: go\n---\nText\n", i, i)
+	}
+	return posts
+}
+
+func TestSitemapIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		pages int
+	}{
+		// The home page and five posts make six URLs
+		{name: "single sitemap", size: 50000, pages: 0},
+		{name: "exactly full", size: 6, pages: 0},
+		{name: "index", size: 4, pages: 2},
+		{name: "one URL per sitemap", size: 1, pages: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, sitemapPosts(5), func(c *Config) { c.SitemapSize = tt.size })
+
+			rec := serve(SitemapHandler, "/sitemap.xml", nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d", rec.Code)
+			}
+
+			if tt.pages == 0 {
+				var set URLSet
+				if err := xml.Unmarshal(rec.Body.Bytes(), &set); err != nil {
+					t.Fatal(err)
+				}
+				if len(set.URLs) != 6 {
+					t.Errorf("got %d URLs, want 6", len(set.URLs))
+				}
+				if rec := serve(SitemapPageHandler, "/sitemap-1.xml", map[string]string{"page": "1"}); rec.Code != http.StatusNotFound {
+					t.Errorf("got status %d for a page without an index", rec.Code)
+				}
+				return
+			}
+
+			var index SitemapIndex
+			if err := xml.Unmarshal(rec.Body.Bytes(), &index); err != nil {
+				t.Fatal(err)
+			}
+			if len(index.Sitemaps) != tt.pages {
+				t.Fatalf("got %d sitemaps in the index, want %d", len(index.Sitemaps), tt.pages)
+			}
+
+			total := 0
+			for i, sitemap := range index.Sitemaps {
+				page := fmt.Sprint(i + 1)
+				if want := "http://example.com/sitemap-" + page + ".xml"; sitemap.Loc != want {
+					t.Errorf("got %q, want %q", sitemap.Loc, want)
+				}
+				rec := serve(SitemapPageHandler, "/sitemap-"+page+".xml", map[string]string{"page": page})
+				var set URLSet
+				if err := xml.Unmarshal(rec.Body.Bytes(), &set); err != nil {
+					t.Fatal(err)
+				}
+				if len(set.URLs) == 0 || len(set.URLs) > tt.size {
+					t.Errorf("sitemap %s has %d URLs, want 1 to %d", page, len(set.URLs), tt.size)
+				}
+				total += len(set.URLs)
+			}
+			if total != 6 {
+				t.Errorf("the sitemaps have %d URLs, want 6", total)
+			}
+
+			last := fmt.Sprint(tt.pages + 1)
+			if rec := serve(SitemapPageHandler, "/sitemap-"+last+".xml", map[string]string{"page": last}); rec.Code != http.StatusNotFound {
+				t.Errorf("got status %d past the last sitemap", rec.Code)
+			}
+		})
+	}
+}
+
+func TestSitemapTags(t *testing.T) {
+	posts := map[string]string{
+		"old.md":   "title: Old\ndate: 2024-01-01T00:00:00Z\ntags: go\n---\nOld\n",
+		"new.md":   "title: New\ndate: 2024-02-01T00:00:00Z\ntags: go, web\n---\nNew\n",
+		"about.md": "title: About\ntype: page\ntags: me\n---\nAbout\n",
+		"draft.md": "title: Draft\ndate: 2024-03-01T00:00:00Z\ntags: secret\ndraft: true\n---\nDraft\n",
+	}
+
+	tests := []struct {
+		name string
+		tags bool
+		want []SitemapURL
+	}{
+		{name: "off", tags: false},
+		{
+			name: "on",
+			tags: true,
+			want: []SitemapURL{
+				{Loc: "http://example.com/tag/go", LastMod: "2024-02-01T00:00:00Z", ChangeFreq: "weekly", Priority: "0.3"},
+				{Loc: "http://example.com/tag/web", LastMod: "2024-02-01T00:00:00Z", ChangeFreq: "weekly", Priority: "0.3"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, posts, func(c *Config) { c.Sitemap.Tags = tt.tags })
+
+			var set URLSet
+			if err := xml.Unmarshal(serve(SitemapHandler, "/sitemap.xml", nil).Body.Bytes(), &set); err != nil {
+				t.Fatal(err)
+			}
+
+			var tags []SitemapURL
+			for _, u := range set.URLs {
+				switch {
+				case strings.Contains(u.Loc, "/tag/"):
+					tags = append(tags, u)
+				case u.Loc == "http://example.com/":
+					if u.Priority != "1.0" || u.ChangeFreq != "daily" {
+						t.Errorf("home page %+v", u)
+					}
+				case strings.HasSuffix(u.Loc, "/about"):
+					if u.Priority != "0.5" || u.ChangeFreq != "yearly" {
+						t.Errorf("page %+v", u)
+					}
+				default:
+					if u.Priority != "0.8" || u.ChangeFreq != "monthly" {
+						t.Errorf("post %+v", u)
+					}
+				}
+			}
+			if !reflect.DeepEqual(tags, tt.want) {
+				t.Errorf("got tag URLs %+v, want %+v", tags, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSuggestions is how many similar posts the not found page lists at most
+const maxSuggestions = 3
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// minInt returns the smallest of its arguments
+func minInt(first int, rest ...int) int {
+	m := first
+	for _, n := range rest {
+		if n < m {
+			m = n
+		}
+	}
+	return m
+}
+
+// SuggestPosts returns the posts whose slug is within maxDistance edits of
+// slug, closest first
+func SuggestPosts(slug string, posts []Post, maxDistance int) []Post {
+	type candidate struct {
+		post     Post
+		distance int
+	}
+
+	slug = strings.ToLower(trimPostExtension(slug))
+	var candidates []candidate
+	for _, post := range posts {
+		if d := levenshtein(slug, strings.ToLower(post.Slug())); d <= maxDistance {
+			candidates = append(candidates, candidate{post, d})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	var suggestions []Post
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].post)
+	}
+	return suggestions
+}
+
+// postNotFound renders the not found page for a missing post, suggesting the
+// posts with a similar slug in case it was a typo
+func postNotFound(w http.ResponseWriter, r *http.Request, slug string) {
+	posts, err := GetAllPosts()
+	if err != nil {
+		log.Printf("Error getting all posts: %v", err)
+	}
+
+	var suggestions []Post
+	if config.SuggestDistance > 0 {
+		suggestions = SuggestPosts(slug, ListedPosts(posts, time.Now()), config.SuggestDistance)
+	}
+
+	data := newPageData(r, false, posts)
+	data.Suggestions = suggestions
+
+	tmpl, err := localizedTemplate(w, r, pageTemplate("404"))
+	if err != nil {
+		log.Printf("Error localizing templates: %v", err)
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeHTML)
+	w.WriteHeader(http.StatusNotFound)
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("Error executing template: %v", err)
+	}
+}
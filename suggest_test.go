@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "go", b: "", want: 2},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "hello-world", b: "hello-wrold", want: 2},
+		{a: "café", b: "cafe", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := levenshtein(tt.a, tt.b); got != tt.want {
+				t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestPosts(t *testing.T) {
+	withConfig(t, nil)
+
+	posts := []Post{
+		{Filename: "hello-world.md"},
+		{Filename: "hello-word.md"},
+		{Filename: "hello-there.md"},
+		{Filename: "goodbye.md"},
+		{Filename: "hello-worlds.md"},
+		{Filename: "jello-world.md"},
+	}
+
+	tests := []struct {
+		name string
+		slug string
+		want []string
+	}{
+		{name: "typo", slug: "helo-world", want: []string{"hello-world.md", "hello-word.md", "hello-worlds.md"}},
+		{name: "at most three", slug: "hello-world", want: []string{"hello-world.md", "hello-word.md", "hello-worlds.md"}},
+		{name: "case and extension", slug: "GOODBYE.md", want: []string{"goodbye.md"}},
+		{name: "nothing close", slug: "something-else", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filenames(SuggestPosts(tt.slug, posts, 2)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotFoundSuggestion(t *testing.T) {
+	tests := []struct {
+		name     string
+		distance int
+		want     bool
+	}{
+		{name: "enabled", distance: 3, want: true},
+		{name: "disabled", distance: 0, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{
+				"hello-world.md": "title: Hello world\ndate: 2024-01-01T00:00:00Z\n---\nHello\n",
+			}, func(c *Config) { c.SuggestDistance = tt.distance })
+			withTemplates(t)
+
+			rec := serve(PostHandler, "/post/helo-wrld", map[string]string{"title": "helo-wrld"})
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("got status %d", rec.Code)
+			}
+			body := rec.Body.String()
+			if got := strings.Contains(body, "Did you mean"); got != tt.want {
+				t.Errorf("suggestions shown: %v, want %v", got, tt.want)
+			}
+			if !strings.Contains(body, `<a href="/post/hello-world">Hello world</a>`) {
+				t.Errorf("no link to the post, suggested or recent: %s", body)
+			}
+		})
+	}
+}
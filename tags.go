@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// allTags returns the tags used by any of the posts, sorted
+func allTags(posts []Post) []string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, post := range posts {
+		for _, tag := range post.TagList() {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// taggedPosts returns the posts with a tag
+func taggedPosts(posts []Post, tag string) []Post {
+	var tagged []Post
+	for _, post := range posts {
+		if post.HasTag(tag) {
+			tagged = append(tagged, post)
+		}
+	}
+	return tagged
+}
+
+// tagURL returns the path of the page of a tag
+func tagURL(tag string) string {
+	path := "/tag/" + url.PathEscape(tag)
+	if trailingSlashPolicy == TrailingSlashAdd {
+		path += "/"
+	}
+	return path
+}
+
+// TagHandler lists the posts with a tag like the index does
+func TagHandler(w http.ResponseWriter, r *http.Request) {
+	tag := mux.Vars(r)["tag"]
+
+	tmpl, err := localizedTemplate(w, r, pageTemplate("index"))
+	if err != nil {
+		log.Printf("Error localizing templates: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	posts, err := GetAllPosts()
+	if err != nil {
+		log.Printf("Error getting all posts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tagged := taggedPosts(ListedPosts(posts, time.Now()), tag)
+	if len(tagged) == 0 {
+		log.Printf("No posts tagged: %s", tag)
+		http.NotFound(w, r)
+		return
+	}
+
+	data := newPageData(r, false, posts)
+	data.Tag = tag
+	data.Posts = SortForIndex(tagged)
+
+	w.Header().Set("Content-Type", contentTypeHTML)
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
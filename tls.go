@@ -0,0 +1,30 @@
+package main
+
+import (
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSConfig sets up HTTPS with certificates from Let's Encrypt. It's off, and
+// TLS left to whatever is in front of the server, unless there are domains
+type TLSConfig struct {
+	Domains  []string `yaml:"domains"`
+	Email    string   `yaml:"email"`
+	CacheDir string   `yaml:"cache_dir"`
+	HTTPAddr string   `yaml:"http_addr"`
+}
+
+// Enabled reports whether the server does TLS itself
+func (c TLSConfig) Enabled() bool {
+	return len(c.Domains) > 0
+}
+
+// certManager returns the manager getting and renewing the certificates of
+// the configured domains, and only those
+func certManager(c TLSConfig) *autocert.Manager {
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(c.Domains...),
+		Cache:      autocert.DirCache(c.CacheDir),
+		Email:      c.Email,
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// tocMarker is replaced with the table of contents of the post
+const tocMarker = "[[TOC]]"
+
+// nodeText returns the plain text content of a node
+func nodeText(node ast.Node) string {
+	var b strings.Builder
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		if leaf := n.AsLeaf(); leaf != nil && entering {
+			b.Write(leaf.Literal)
+		}
+		return ast.GoToNext
+	})
+	return b.String()
+}
+
+// renderTOC builds a nested list linking to the headings of a document
+func renderTOC(doc ast.Node) string {
+	var b strings.Builder
+	var levels []int
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		heading, ok := node.(*ast.Heading)
+		if !ok || !entering || heading.HeadingID == "" {
+			return ast.GoToNext
+		}
+
+		// Open a list for every deeper level, close them when going back up
+		switch {
+		case len(levels) == 0 || heading.Level > levels[len(levels)-1]:
+			b.WriteString("<ul>")
+			levels = append(levels, heading.Level)
+		default:
+			for len(levels) > 1 && heading.Level < levels[len(levels)-1] {
+				b.WriteString("</li></ul>")
+				levels = levels[:len(levels)-1]
+			}
+			b.WriteString("</li>")
+		}
+
+		fmt.Fprintf(&b, `<li><a href="#%s">%s</a>`, html.EscapeString(heading.HeadingID), html.EscapeString(nodeText(heading)))
+		return ast.SkipChildren
+	})
+
+	for range levels {
+		b.WriteString("</li></ul>")
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+	return `<nav class="toc">` + b.String() + `</nav>`
+}
+
+// insertTOC replaces the paragraph holding the TOC marker with the table of contents
+func insertTOC(body string, toc string) string {
+	return strings.ReplaceAll(body, "<p>"+tocMarker+"</p>", toc)
+}
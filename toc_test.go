@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTOC(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "marker",
+			source: "[[TOC]]\n\n# One\n\n## One and a half\n\n# Two\n",
+			want: []string{
+				`<nav class="toc"><ul><li><a href="#one">One</a><ul><li><a href="#one-and-a-half">One and a half</a></li></ul></li><li><a href="#two">Two</a></li></ul></nav>`,
+			},
+			notWant: []string{tocMarker},
+		},
+		{
+			name:    "no marker",
+			source:  "# One\n\n# Two\n",
+			notWant: []string{`class="toc"`},
+		},
+		{
+			name:    "marker in code",
+			source:  "`[[TOC]]`\n\n# One\n",
+			want:    []string{"<code>" + tocMarker + "</code>"},
+			notWant: []string{`class="toc"`},
+		},
+		{
+			name:    "marker without headings",
+			source:  "[[TOC]]\n\nJust text.\n",
+			want:    []string{"<p>Just text.</p>"},
+			notWant: []string{tocMarker, `class="toc"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, nil)
+
+			post, err := parsePostBytes("toc.md", []byte("title: TOC\n---\n"+tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := string(post.Body)
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body has no %q: %s", want, body)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(body, notWant) {
+					t.Errorf("body has %q: %s", notWant, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebhookConfig lists the endpoints notified when posts are published or updated
+type WebhookConfig struct {
+	URLs    []string      `yaml:"urls"`
+	Timeout time.Duration `yaml:"timeout"`
+	Retries int           `yaml:"retries"`
+}
+
+// WebhookPayload is the JSON body POSTed to the webhooks
+type WebhookPayload struct {
+	Slug   string `json:"slug"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+	Action string `json:"action"`
+}
+
+// Webhook actions
+const (
+	ActionPublish = "publish"
+	ActionUpdate  = "update"
+)
+
+// postChanges compares two sets of posts and returns what was published or
+// updated in between. Only published posts are taken into account
+func postChanges(before []Post, after []Post, now time.Time) []WebhookPayload {
+	previous := map[string]Post{}
+	for _, post := range PublishedPosts(before, now) {
+		previous[post.Filename] = post
+	}
+
+	var changes []WebhookPayload
+	for _, post := range PublishedPosts(after, now) {
+		action := ""
+		if old, ok := previous[post.Filename]; !ok {
+			action = ActionPublish
+		} else if !old.ModTime.Equal(post.ModTime) {
+			action = ActionUpdate
+		} else {
+			continue
+		}
+
+		changes = append(changes, WebhookPayload{
+			Slug:   post.Slug(),
+			Title:  post.Title,
+			URL:    strings.TrimRight(config.BaseURL, "/") + postURL(post),
+			Action: action,
+		})
+	}
+	return changes
+}
+
+// notifyWebhooks sends every change to every configured webhook in the
+// background, so serving never waits for them
+func notifyWebhooks(changes []WebhookPayload) {
+	for _, change := range changes {
+		body, err := json.Marshal(change)
+		if err != nil {
+			log.Printf("Error encoding webhook payload: %v", err)
+			continue
+		}
+		for _, url := range config.Webhooks.URLs {
+			go sendWebhook(url, body)
+		}
+	}
+}
+
+// sendWebhook POSTs a payload, retrying with an increasing delay on failure
+func sendWebhook(url string, body []byte) {
+	client := &http.Client{Timeout: config.Webhooks.Timeout}
+
+	delay := time.Second
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return
+			}
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+
+		if attempt >= config.Webhooks.Retries {
+			log.Printf("Error calling webhook %s, giving up: %v", url, err)
+			return
+		}
+		log.Printf("Error calling webhook %s, retrying in %s: %v", url, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// wikiLink matches [[slug]] and [[slug|Title]]
+var wikiLink = regexp.MustCompile(`\[\[([^\[\]|]+)(?:\|([^\[\]]+))?\]\]`)
+
+// markdownEscaper escapes the characters that mean something in Markdown text
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+	`<`, `\<`, `>`, `\>`, `&`, `\&`,
+)
+
+// Wiki-links are rendered as links with these schemes, wiki:slug, or
+// wiki-title:slug for the ones titled after the post, and only resolved when
+// the posts are loaded, as posts come and go without the ones linking to them
+// being rendered again
+const (
+	wikiScheme      = "wiki"
+	wikiTitleScheme = "wiki-title"
+)
+
+// renderedWikiLink matches the wiki-links in a rendered post
+var renderedWikiLink = regexp.MustCompile(`<a href="(wiki|wiki-title):([^"]*)">(.*?)</a>`)
+
+// expandWikiLinks rewrites the wiki-links in a post's Markdown as links for
+// resolveWikiLinks to point at the posts with those slugs. Code blocks and
+// code spans are left alone, and so is the table of contents marker
+func expandWikiLinks(source string) string {
+	if !strings.Contains(source, "[[") {
+		return source
+	}
+
+	var out strings.Builder
+	inCode := false
+	for _, line := range strings.SplitAfter(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+		}
+		if inCode || trimmed == tocMarker || !wikiLink.MatchString(line) {
+			out.WriteString(line)
+			continue
+		}
+
+		// Every other piece between backticks is a code span
+		pieces := strings.Split(line, "`")
+		for i := 0; i < len(pieces); i += 2 {
+			pieces[i] = wikiLink.ReplaceAllStringFunc(pieces[i], func(link string) string {
+				m := wikiLink.FindStringSubmatch(link)
+				slug, title := strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+				// A title in the link is Markdown already, the slug isn't
+				if title == "" {
+					return fmt.Sprintf("[%s](%s:%s)", markdownEscaper.Replace(slug), wikiTitleScheme, url.PathEscape(slug))
+				}
+				return fmt.Sprintf("[%s](%s:%s)", title, wikiScheme, url.PathEscape(slug))
+			})
+		}
+		out.WriteString(strings.Join(pieces, "`"))
+	}
+	return out.String()
+}
+
+// publishedBySlug indexes the posts published at the given time by slug
+func publishedBySlug(posts []Post, now time.Time) map[string]Post {
+	published := map[string]Post{}
+	for _, post := range PublishedPosts(posts, now) {
+		published[post.Slug()] = post
+	}
+	return published
+}
+
+// resolveWikiLinks points the wiki-links in a rendered body at the published
+// posts they name, titling them after the post where the link has no title.
+// Links to anything else become a span with a broken class, and their slugs
+// are returned
+func resolveWikiLinks(body template.HTML, published map[string]Post) (template.HTML, []string) {
+	if !strings.Contains(string(body), `<a href="wiki`) {
+		return body, nil
+	}
+
+	var broken []string
+	resolved := renderedWikiLink.ReplaceAllStringFunc(string(body), func(link string) string {
+		m := renderedWikiLink.FindStringSubmatch(link)
+		slug, err := url.PathUnescape(html.UnescapeString(m[2]))
+		if err != nil {
+			slug = m[2]
+		}
+		text := m[3]
+
+		target, ok := published[slug]
+		if !ok {
+			broken = append(broken, slug)
+			return `<span class="broken">` + text + `</span>`
+		}
+		if m[1] == wikiTitleScheme {
+			text = html.EscapeString(target.Title)
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(postURL(target)), text)
+	})
+	return template.HTML(resolved), broken
+}
+
+// resolveAllWikiLinks returns a copy of the posts with their wiki-links
+// resolved against each other. With warn set the broken ones are logged
+func resolveAllWikiLinks(posts []Post, now time.Time, warn bool) []Post {
+	published := publishedBySlug(posts, now)
+	resolved := make([]Post, len(posts))
+	for i, post := range posts {
+		var broken []string
+		post.Body, broken = resolveWikiLinks(post.Body, published)
+		if warn {
+			for _, slug := range broken {
+				log.Printf("Warning: %s links to %q, which isn't a published post", post.Filename, slug)
+			}
+		}
+		resolved[i] = post
+	}
+	return resolved
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadedBody returns the body of a post as GetAllPosts has it, wiki-links
+// resolved
+func loadedBody(t *testing.T, filename string) string {
+	t.Helper()
+
+	posts, err := GetAllPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, post := range posts {
+		if post.Filename == filename {
+			return string(post.Body)
+		}
+	}
+	t.Fatalf("no post %s", filename)
+	return ""
+}
+
+func TestWikiLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		notWant string
+	}{
+		{
+			name:   "slug",
+			source: "See [[target]].",
+			want:   `See <a href="/post/target">Target &amp; co</a>.`,
+		},
+		{
+			name:   "slug and title",
+			source: "See [[target|*that* post]].",
+			want:   `See <a href="/post/target"><em>that</em> post</a>.`,
+		},
+		{
+			name:   "spaces",
+			source: "See [[ target | it ]].",
+			want:   `See <a href="/post/target">it</a>.`,
+		},
+		{
+			name:    "unresolved",
+			source:  "See [[missing]].",
+			want:    `See <span class="broken">missing</span>.`,
+			notWant: "<a href",
+		},
+		{
+			name:    "unresolved with a title",
+			source:  "See [[missing|that]].",
+			want:    `See <span class="broken">that</span>.`,
+			notWant: "<a href",
+		},
+		{
+			name:    "draft",
+			source:  "See [[draft]].",
+			want:    `See <span class="broken">draft</span>.`,
+			notWant: "/post/draft",
+		},
+		{
+			name:   "markdown in the slug",
+			source: "See [[a_b*c]].",
+			want:   `See <span class="broken">a_b*c</span>.`,
+		},
+		{
+			name:   "code span",
+			source: "Write `[[target]]` for [[target]].",
+			want:   `Write <code>[[target]]</code> for <a href="/post/target">`,
+		},
+		{
+			name:    "code block",
+			source:  "```\n[[target]]\n```\n",
+			want:    "<code>[[target]]\n</code>",
+			notWant: "<a href",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPosts(t, map[string]string{
+				"post.md":   "title: Post\ndate: 2024-01-01T00:00:00Z\n---\n" + tt.source + "\n",
+				"target.md": "title: Target & co\ndate: 2024-01-02T00:00:00Z\n---\nTarget\n",
+				"draft.md":  "title: Draft\ndate: 2024-01-03T00:00:00Z\ndraft: true\n---\nDraft\n",
+			}, nil)
+
+			body := loadedBody(t, "post.md")
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("body has no %q: %s", tt.want, body)
+			}
+			if tt.notWant != "" && strings.Contains(body, tt.notWant) {
+				t.Errorf("body has %q: %s", tt.notWant, body)
+			}
+		})
+	}
+}
+
+func TestWikiLinksFollowPosts(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"post.md": "title: Post\ndate: 2024-01-01T00:00:00Z\n---\nSee [[later]].\n",
+	})
+	withConfig(t, func(c *Config) { c.ContentDirs = []string{dir} })
+
+	if body := loadedBody(t, "post.md"); !strings.Contains(body, `<span class="broken">later</span>`) {
+		t.Fatalf("a link to a missing post isn't broken: %s", body)
+	}
+
+	// The linking post doesn't change, but the link resolves once its target exists
+	later := "title: Later\ndate: 2024-01-02T00:00:00Z\n---\nLater\n"
+	if err := os.WriteFile(filepath.Join(dir, "later.md"), []byte(later), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if body := loadedBody(t, "post.md"); !strings.Contains(body, `<a href="/post/later">Later</a>`) {
+		t.Errorf("the link didn't resolve: %s", body)
+	}
+}